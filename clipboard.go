@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// copyCommands returns the commands that can write stdin to the clipboard on
+// this platform, in order of preference.
+func copyCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+	cmds := [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append([][]string{{"wl-copy"}}, cmds...)
+	}
+	return cmds
+}
+
+func copyToClipboard(s string) error {
+	for _, args := range copyCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(s)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard command found, install xclip, xsel or wl-copy")
+}
+
+// pasteCommands returns the commands that can write the clipboard to stdout
+// on this platform, in order of preference.
+func pasteCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}}
+	}
+	cmds := [][]string{
+		{"xclip", "-selection", "clipboard", "-o"},
+		{"xsel", "--clipboard", "--output"},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append([][]string{{"wl-paste", "--no-newline"}}, cmds...)
+	}
+	return cmds
+}
+
+func pasteFromClipboard() (string, error) {
+	for _, args := range pasteCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if runtime.GOOS == "windows" {
+			// Get-Clipboard ends its output with a line break.
+			out = []byte(strings.TrimSuffix(string(out), "\r\n"))
+		}
+		return string(out), err
+	}
+	return "", errors.New("no clipboard command found, install xclip, xsel or wl-paste")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// SGR parameters of the parts of the verbose output.
+const (
+	colorChar     = "1"
+	colorName     = "32"
+	colorCategory = "36"
+)
+
+// useColor reports whether output should be colored according to the -color
+// mode. In auto mode it is when out is a terminal and NO_COLOR is not set.
+func useColor(mode string, out io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		f, ok := out.(*os.File)
+		return os.Getenv("NO_COLOR") == "" && ok && term.IsTerminal(int(f.Fd())), nil
+	}
+	return false, fmt.Errorf("invalid color mode %q, expected auto, always or never", mode)
+}
+
+// paint returns s in the color of the SGR parameter code, if output is
+// colored.
+func (o *options) paint(code, s string) string {
+	if !o.colored {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
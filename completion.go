@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// completeBlocks lists the block names -category is completed with, without
+// the ranges -blocks prints after them.
+const completeBlocks = appName + " -offline -quiet -blocks 2>/dev/null | sed \"s/ (U+.*//\""
+
+// completion returns a script for shell that completes the flags of
+// unifind, and the names of the blocks for -category.
+func completion(shell string) (string, error) {
+	var flags []*flag.Flag
+	newFlagSet(&options{}).VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	var b strings.Builder
+	switch shell {
+	case "bash":
+		var names []string
+		for _, f := range flags {
+			names = append(names, "-"+f.Name)
+		}
+		fmt.Fprintf(&b, `_%[1]s() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	if [[ $prev == -category ]]; then
+		local IFS=$'\n'
+		COMPREPLY=($(compgen -W "$(%[2]s)" -- "$cur"))
+		COMPREPLY=("${COMPREPLY[@]// /\\ }")
+	elif [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "%[3]s" -- "$cur"))
+	fi
+}
+complete -F _%[1]s %[1]s
+`, appName, completeBlocks, strings.Join(names, " "))
+	case "zsh":
+		fmt.Fprintf(&b, "#compdef %s\n\n_%s() {\n\tlocal state\n\t_arguments \\\n", appName, appName)
+		for _, f := range flags {
+			name, usage := flag.UnquoteUsage(f)
+			usage = strings.NewReplacer("[", `\[`, "]", `\]`, "'", `'\''`).Replace(usage)
+			switch {
+			case f.Name == "category":
+				fmt.Fprintf(&b, "\t\t'-%s[%s]:%s:->category' \\\n", f.Name, usage, name)
+			case name != "" && !isBoolFlag(f):
+				fmt.Fprintf(&b, "\t\t'-%s[%s]:%s:' \\\n", f.Name, usage, name)
+			default:
+				fmt.Fprintf(&b, "\t\t'-%s[%s]' \\\n", f.Name, usage)
+			}
+		}
+		fmt.Fprintf(&b, `		'*:query:'
+	if [[ $state == category ]]; then
+		local -a blocks
+		blocks=("${(@f)$(%s)}")
+		compadd -a blocks
+	fi
+}
+
+_%s "$@"
+`, completeBlocks, appName)
+	case "fish":
+		for _, f := range flags {
+			_, usage := flag.UnquoteUsage(f)
+			usage = strings.ReplaceAll(usage, "'", `\'`)
+			switch {
+			case f.Name == "category":
+				fmt.Fprintf(&b, "complete -c %s -o %s -x -a '(%s)' -d '%s'\n", appName, f.Name, completeBlocks, usage)
+			case !isBoolFlag(f):
+				fmt.Fprintf(&b, "complete -c %s -o %s -r -d '%s'\n", appName, f.Name, usage)
+			default:
+				fmt.Fprintf(&b, "complete -c %s -o %s -d '%s'\n", appName, f.Name, usage)
+			}
+		}
+	default:
+		return "", fmt.Errorf("can not complete for shell %q, expected bash, zsh or fish", shell)
+	}
+	return b.String(), nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// escape returns the characters of s as escapes of the programming language
+// flavor: \uXXXX for the BMP and \UXXXXXXXX above it for go, c and python,
+// and surrogate pairs of \uXXXX above the BMP for json.
+func escape(flavor, s string) (string, error) {
+	switch flavor {
+	case "go", "python", "c", "json":
+	default:
+		return "", fmt.Errorf("invalid escape flavor %q, expected go, c, python or json", flavor)
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch flavor {
+		case "go", "python":
+			if r > 0xFFFF {
+				fmt.Fprintf(&b, `\U%08x`, r)
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		case "c":
+			if r > 0xFFFF {
+				fmt.Fprintf(&b, `\U%08X`, r)
+			} else {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			}
+		case "json":
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		}
+	}
+	return b.String(), nil
+}
@@ -1,3 +1,10 @@
 module github.com/rafael-luigi-bekkema/unifind
 
 go 1.17
+
+require (
+	golang.org/x/term v0.13.0
+	golang.org/x/text v0.13.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
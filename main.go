@@ -2,251 +2,1737 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"io/fs"
-	"net/http"
+	"math/rand"
 	"os"
-	"path"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/rafael-luigi-bekkema/unifind/ucd"
+	"golang.org/x/text/unicode/norm"
 )
 
-const unicodeIndex = "https://www.unicode.org/Public/UCD/latest/ucd/Index.txt"
-const unicodeNamesList = "https://www.unicode.org/Public/UCD/latest/ucd/NamesList.txt"
 const appName = "unifind"
 
-func fetchUnicodeURL(url string) (io.ReadCloser, error) {
-	cacheDir, err := os.UserCacheDir()
+// errorf prints a message that is not part of the output to stderr, unless
+// -quiet is set.
+func (o *options) errorf(format string, args ...interface{}) {
+	if !o.quiet {
+		fmt.Fprintf(o.stderr, format, args...)
+	}
+}
+
+func envBool(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
+}
+
+type options struct {
+	cats          bool
+	codes         bool
+	verbose       bool
+	veryVerbose   bool
+	verbosity     int
+	json          bool
+	jsonLines     bool
+	name          bool
+	codePoint     bool
+	codeRange     string
+	limit         int
+	offline       bool
+	refresh       bool
+	maxAge        int
+	namesList     string
+	index         string
+	version       string
+	caseSens      bool
+	regexp        bool
+	exact         bool
+	word          bool
+	fuzzy         bool
+	prefix        bool
+	sortBy        string
+	bytes         bool
+	utf16         bool
+	entity        bool
+	copy          bool
+	out           string
+	input         io.Reader
+	stdout        io.Writer
+	stderr        io.Writer
+	cache         *ucd.Cache
+	interactive   bool
+	exclude       string
+	includeAll    bool
+	category      string
+	notCategories listFlag
+	subcategory   string
+	raw           bool
+	dec           bool
+	count         bool
+	context       int
+	color         string
+	colored       bool
+	quiet         bool
+	stats         bool
+	timeout       time.Duration
+	clearCache    bool
+	cacheDir      string
+	cacheInfo     bool
+	completion    string
+	manpage       bool
+	strict        bool
+	first         bool
+	stdin         bool
+	csv           bool
+	tsv           bool
+	width         bool
+	gc            string
+	emoji         bool
+	useIndex      bool
+	decompose     bool
+	nf            string
+	random        bool
+	table         bool
+	group         bool
+	blocks        bool
+	embedded      bool
+	noAutoCP      bool
+	near          bool
+	script        string
+	format        string
+	tmpl          *template.Template
+	any           bool
+	since         string
+	distinct      string
+	countBy       string
+	paste         bool
+	ascii         bool
+	bmp           bool
+	plane         int
+	escape        string
+	seed          int64
+	names         ucd.Names
+	// start and end are the code point range of -range, -ascii, -bmp and
+	// -plane.
+	start, end rune
+	less       func(a, b ucd.CodePoint) bool
+	value      func(ucd.CodePoint) string
+}
+
+// mode returns the match mode selected by the mutually exclusive -word,
+// -regex, -exact, -fuzzy and -prefix flags.
+func (o *options) mode() (ucd.Mode, error) {
+	mode := ucd.Substring
+	var flags []string
+	for _, m := range []struct {
+		set  bool
+		flag string
+		mode ucd.Mode
+	}{
+		{o.word, "-word", ucd.Words},
+		{o.regexp, "-regex", ucd.Regexp},
+		{o.exact, "-exact", ucd.Exact},
+		{o.fuzzy, "-fuzzy", ucd.Fuzzy},
+		{o.prefix, "-prefix", ucd.Prefix},
+	} {
+		if m.set {
+			mode = m.mode
+			flags = append(flags, m.flag)
+		}
+	}
+	if len(flags) > 1 {
+		return mode, fmt.Errorf("%s are mutually exclusive", strings.Join(flags, " and "))
+	}
+	return mode, nil
+}
+
+// parseFlags parses the flags of args. -h and -help print the usage to
+// stdout and return flag.ErrHelp, invalid flags print it to stderr and
+// return errUsage.
+func parseFlags(args []string, stdout, stderr io.Writer) (*options, []string, error) {
+	var opts options
+	fs := newFlagSet(&opts)
+	fs.SetOutput(stderr)
+	usage := fs.Usage
+	fs.Usage = func() {}
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		fs.SetOutput(stdout)
+		usage()
+		return nil, nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could not find user cache dir: %w", err)
+		usage()
+		return nil, nil, errUsage
 	}
-	cacheDir = filepath.Join(cacheDir, appName, "ucd")
-	fileName := path.Base(url)
-	cachePath := filepath.Join(cacheDir, fileName)
-	f, err := os.Open(cachePath)
-	if err == nil {
-		return f, nil
+	return &opts, fs.Args(), nil
+}
+
+// errUsage is returned for invalid flags, after the error and the usage
+// have been printed.
+var errUsage = errors.New("invalid usage")
+
+// querySyntax, examples and exitStatus are the sections of the usage and
+// of the man page.
+const querySyntax = `The query matches characters whose name contains all of its words.
+Words between double quotes match as a phrase, a word starting with -
+must not match and | separates alternatives, e.g. 'latin -small | cyrillic'.
+Put -- before a query that starts with -. A query that looks like a code
+point, like U+2764, is looked up instead. Only the names of characters are
+searched, so finding a character by an alias, like line feed, needs -any,
+which also searches their aliases, comments and blocks. -exact matches
+aliases too.`
+
+var examples = []struct{ command, what string }{
+	{"unifind arrow", "print the characters with arrow in their name"},
+	{"unifind -v -exact 'black heart suit'", "print the character named black heart suit with its name"},
+	{"unifind -any 'line feed'", "print the characters with line feed in their name or an alias, like U+000A"},
+	{"unifind -name é", "print the name of é"},
+	{"unifind U+2764", "print the name of U+2764"},
+	{"unifind -category arrows -table", "print the arrows block as a table"},
+	{"unifind -emoji -first face", "print the first emoji with face in its name"},
+}
+
+const exitStatus = "The exit status is 0 if anything matched, 1 if nothing did and 2 on errors."
+
+// newFlagSet returns the flags of unifind, which set the fields of opts.
+func newFlagSet(opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(appName, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s [flags] [query ...]\n\n%s\n\n%s\n\nExamples:\n", appName, querySyntax, exitStatus)
+		for _, e := range examples {
+			fmt.Fprintf(w, "  %s\n    \t%s\n", e.command, e.what)
+		}
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs.PrintDefaults()
 	}
-	if !errors.Is(err, fs.ErrNotExist) {
-		return nil, fmt.Errorf("could not open file %q: %w", cachePath, err)
+	fs.BoolVar(&opts.group, "group", false, "print the matches under the name of their block, sorted by block name")
+	fs.BoolVar(&opts.blocks, "blocks", false, "list every block of the NamesList with its range (with -include-all also the excluded blocks)")
+	fs.StringVar(&opts.distinct, "distinct", "", "list the distinct values of `field` of the matches with their number of matches: category, subcategory, script, gc, age or plane")
+	fs.StringVar(&opts.countBy, "count-by", "", "like -distinct, but list the values of `field` with the most matches first")
+	fs.BoolVar(&opts.cats, "cats", false, "list the categories of the matches with their range and number of matches instead of the matches, by name or with -sort codepoint by range")
+	fs.BoolVar(&opts.count, "count", false, "print the number of matches, or of categories with -cats, instead of the matches")
+	fs.BoolVar(&opts.codes, "c", false, "print the code point (U+XXXX) of each match")
+	fs.BoolVar(&opts.dec, "dec", false, "print the decimal code point of each match")
+	fs.BoolVar(&opts.width, "width", false, "print the number of terminal columns each match takes up")
+	fs.BoolVar(&opts.verbose, "v", false, "print each match with its name, like -verbose 1")
+	fs.BoolVar(&opts.veryVerbose, "vv", false, "print each match with its name, category and subcategory, like -verbose 2")
+	fs.IntVar(&opts.verbosity, "verbose", 0, "print each match with more details at `level` 1 to 3: the name, also the block and subcategory, and also the aliases, properties, decomposition and age (reads UnicodeData.txt, Scripts.txt and DerivedAge.txt)")
+	fs.BoolVar(&opts.bytes, "bytes", false, "print the UTF-8 bytes of each match")
+	fs.BoolVar(&opts.utf16, "utf16", false, "print the UTF-16 code units of each match")
+	fs.StringVar(&opts.escape, "escape", "", "print each match as escapes of the `flavor` go, c, python or json, like \\u00e9")
+	fs.BoolVar(&opts.entity, "entity", false, "print the HTML character references of each match")
+	fs.BoolVar(&opts.quiet, "quiet", false, "do not report downloads of UCD files, skipped lines and other warnings on stderr, only errors")
+	fs.BoolVar(&opts.stats, "stats", false, "print how long downloading, parsing and searching took, the lines parsed and the number of matches on stderr")
+	fs.BoolVar(&opts.raw, "raw", false, "print control and other non-printing characters as they are instead of as U+XXXX")
+	fs.BoolVar(&opts.json, "json", false, "print the matches as a JSON array")
+	fs.BoolVar(&opts.jsonLines, "json-lines", false, "print each match as a JSON object on a line of its own, as it is found")
+	fs.BoolVar(&opts.csv, "csv", false, "print the matches as CSV with a header row")
+	fs.StringVar(&opts.format, "format", "", "print each match with the Go text/`template`, like '{{char .Chr}} {{printf \"%U\" .Chr}} {{.Desc}} {{.Category.Name}}'")
+	fs.BoolVar(&opts.table, "table", false, "print the matches as aligned columns of character, code point, name and category")
+	fs.BoolVar(&opts.tsv, "tsv", false, "print the matches as tab separated values with a header row")
+	fs.BoolVar(&opts.any, "any", false, "also match the aliases, comments and cross references of characters and the names of their block and subcategory, not only their name")
+	fs.BoolVar(&opts.caseSens, "case", false, "match the query case sensitively")
+	fs.BoolVar(&opts.regexp, "regex", false, "match the query as a regular expression instead of as words")
+	fs.BoolVar(&opts.emoji, "emoji", false, "search the emoji, including sequences like flags, instead of the characters")
+	fs.BoolVar(&opts.useIndex, "use-index", false, "search the terms of the index of the Unicode Standard, Index.txt, instead of the character names")
+	fs.BoolVar(&opts.exact, "exact", false, "only match characters whose name or an alias is exactly the query")
+	fs.BoolVar(&opts.word, "word", false, "only match the words of the query as whole words")
+	fs.BoolVar(&opts.fuzzy, "fuzzy", false, "also match words with typos, closest matches first")
+	fs.BoolVar(&opts.prefix, "prefix", false, "only match characters whose name starts with the query, sorted by name")
+	fs.BoolVar(&opts.interactive, "i", false, "browse the characters interactively, Enter copies the selected one")
+	fs.StringVar(&opts.category, "category", "", "only match characters in the blocks whose name contains `name`")
+	fs.Var(&opts.notCategories, "not-category", "do not match characters in the blocks whose name contains `name`, can be repeated")
+	fs.StringVar(&opts.subcategory, "subcategory", "", "only match characters in the subcategories whose name contains `name`")
+	fs.BoolVar(&opts.stdin, "stdin", false, "run each line of stdin as a query, or look it up if it is a U+XXXX or 0xXXXX code point")
+	fs.StringVar(&opts.since, "since", "", "only match characters added in Unicode `version`, like 15.0, or later (reads DerivedAge.txt)")
+	fs.StringVar(&opts.script, "script", "", "only match characters of `script`, like Cyrillic (reads Scripts.txt)")
+	fs.StringVar(&opts.gc, "gc", "", "only match characters of general `category`, like Lu, or L for all letters (reads UnicodeData.txt)")
+	fs.BoolVar(&opts.near, "near", false, "print the characters that look like the character in the query, with their script (reads confusables.txt and Scripts.txt)")
+	fs.BoolVar(&opts.decompose, "decompose", false, "print the characters the character in the query decomposes into")
+	fs.BoolVar(&opts.name, "name", false, "look up the name of each character in the query")
+	fs.StringVar(&opts.nf, "nf", "NFC", "normalize the characters of -name to normalization `form` NFC, NFD, NFKC or NFKD first")
+	fs.BoolVar(&opts.noAutoCP, "no-autocp", false, "search for a query that looks like a code point (U+XXXX, 0xXXXX or hex with a digit) as text, instead of looking it up")
+	fs.BoolVar(&opts.codePoint, "cp", false, "look up the code point in the query (U+XXXX, 0xXXXX or decimal)")
+	fs.BoolVar(&opts.ascii, "ascii", false, "only list matches in ASCII, U+0000..U+007F")
+	fs.BoolVar(&opts.bmp, "bmp", false, "only list matches in the Basic Multilingual Plane, U+0000..U+FFFF")
+	fs.IntVar(&opts.plane, "plane", -1, "only list matches in plane `n` from 0 to 16, like 0 for the BMP or 1 for most emoji (-1 means every plane)")
+	fs.StringVar(&opts.codeRange, "range", "", "only list matches in the inclusive code point `range`, e.g. U+2600..U+26FF")
+	fs.StringVar(&opts.sortBy, "sort", "", "sort the matches by `field`: codepoint, name or category (default file order)")
+	fs.StringVar(&opts.color, "color", "auto", "color the output: `when` is auto, always or never (auto colors when printing to a terminal and NO_COLOR is not set)")
+	fs.IntVar(&opts.context, "context", 0, "also print the `n` characters before and after each match in code point order")
+	fs.BoolVar(&opts.paste, "paste", false, "look up the name of each character on the clipboard, like -name")
+	fs.BoolVar(&opts.copy, "copy", false, "copy the match to the clipboard, the query must match exactly one character")
+	fs.StringVar(&opts.out, "out", "", "write the output to `file` instead of stdout, it is created or truncated")
+	fs.BoolVar(&opts.first, "first", false, "print only the first match, and fail if there is none")
+	fs.BoolVar(&opts.random, "random", false, "print one randomly chosen match, or any character without a query")
+	fs.Int64Var(&opts.seed, "seed", 0, "seed `n` for -random, for the same choice every time (0 means a different one each run)")
+	fs.IntVar(&opts.limit, "limit", 0, "print at most `n` matches (0 means no limit)")
+	fs.StringVar(&opts.exclude, "exclude", strings.Join(ucd.DefaultExclude, ","), "leave out the entries of the comma separated `blocks`")
+	fs.BoolVar(&opts.includeAll, "include-all", false, "do not leave out any blocks, overrides -exclude")
+	fs.BoolVar(&opts.embedded, "embedded", false, "only search the built-in subset of common characters, which is also used when unicode.org can not be reached")
+	fs.BoolVar(&opts.offline, "offline", envBool("UNIFIND_OFFLINE"), "never download missing UCD files (env UNIFIND_OFFLINE)")
+	fs.BoolVar(&opts.refresh, "refresh", false, "download the UCD files again even if they are cached")
+	fs.IntVar(&opts.maxAge, "max-age", int(ucd.DefaultMaxAge/(24*time.Hour)), "download cached UCD files again after `days` (0 means never)")
+	fs.DurationVar(&opts.timeout, "timeout", ucd.DefaultTimeout, "give up downloading a UCD file after `duration` (0 means never)")
+	fs.StringVar(&opts.cacheDir, "cache-dir", os.Getenv("UNIFIND_CACHE_DIR"), "keep the cache in `dir` instead of the user cache directory (env UNIFIND_CACHE_DIR)")
+	fs.BoolVar(&opts.cacheInfo, "cache-info", false, "print the cache directory and the files in it and exit")
+	fs.BoolVar(&opts.manpage, "manpage", false, "print the man page in roff and exit")
+	fs.StringVar(&opts.completion, "completion", "", "print the completion script for `shell` (bash, zsh or fish) and exit")
+	fs.BoolVar(&opts.clearCache, "clear-cache", false, "remove the downloaded UCD files and exit")
+	fs.BoolVar(&opts.strict, "strict", false, "fail on invalid lines in the UCD files instead of skipping them")
+	fs.StringVar(&opts.namesList, "namelist", os.Getenv("UNIFIND_NAMESLIST"), "read NamesList.txt from `path` instead of the cache (env UNIFIND_NAMESLIST)")
+	fs.StringVar(&opts.index, "index", os.Getenv("UNIFIND_INDEX"), "read Index.txt for -use-index from `path` instead of the cache (env UNIFIND_INDEX)")
+	fs.StringVar(&opts.version, "version", ucd.LatestVersion, "use the UCD files of Unicode `version`, e.g. 15.0.0")
+	return fs
+}
+
+// setFlag is a flag and whether it is set.
+type setFlag struct {
+	name string
+	set  bool
+}
+
+// setNames returns the names of the flags that are set.
+func setNames(flags ...setFlag) []string {
+	var names []string
+	for _, f := range flags {
+		if f.set {
+			names = append(names, f.name)
+		}
 	}
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return nil, fmt.Errorf("could not make cache path %s: %w", cachePath, err)
+	return names
+}
+
+// validate returns an error naming the flags that contradict each other,
+// instead of letting one of them win.
+func (o *options) validate() error {
+	// What to do instead of searching. The lookups print a line of their
+	// own for each character, which the formats and details do not apply
+	// to.
+	lookups := []setFlag{
+		{"-blocks", o.blocks}, {"-name", o.name}, {"-paste", o.paste}, {"-decompose", o.decompose},
+		{"-near", o.near}, {"-cp", o.codePoint},
 	}
-	resp, err := http.Get(url)
+	actions := append(lookups, setFlag{"-i", o.interactive}, setFlag{"-stdin", o.stdin})
+	formats := o.formats()
+	sources := []setFlag{{"-emoji", o.emoji}, {"-use-index", o.useIndex}}
+	for _, group := range [][]setFlag{
+		actions, append(formats, setFlag{"-group", o.group}), sources,
+		{{"-first", o.first}, {"-limit", o.limit != 0}, {"-random", o.random}},
+		{{"-sort", o.sortBy != ""}, {"-fuzzy", o.fuzzy}, {"-prefix", o.prefix}},
+		{{"-offline", o.offline}, {"-refresh", o.refresh}},
+		{{"-embedded", o.embedded}, {"-namelist", o.namesList != ""}},
+	} {
+		if names := setNames(group...); len(names) > 1 {
+			return fmt.Errorf("%s are mutually exclusive", strings.Join(names, " and "))
+		}
+	}
+	if _, err := o.mode(); err != nil {
+		return err
+	}
+	// The browser has its own way of showing the characters.
+	if lookup := setNames(append(lookups, setFlag{"-i", o.interactive})...); len(lookup) > 0 {
+		if names := o.outputFlags(); len(names) > 0 {
+			return fmt.Errorf("%s can not be combined with %s", strings.Join(names, " and "), lookup[0])
+		}
+	}
+	if o.interactive {
+		if names := setNames(sources...); len(names) > 0 {
+			return fmt.Errorf("%s can not be combined with -i", names[0])
+		}
+	}
+	if o.stdin {
+		output := []setFlag{{"-json", o.json}, {"-json-lines", o.jsonLines}, {"-csv", o.csv}, {"-tsv", o.tsv}, {"-copy", o.copy}}
+		if names := setNames(output...); len(names) > 0 {
+			return fmt.Errorf("%s can not be combined with -stdin", strings.Join(names, " and "))
+		}
+	}
+	format := setNames(formats...)
+	if names := setNames(o.details()...); len(names) > 0 && len(format) > 0 {
+		return fmt.Errorf("%s can not be combined with %s", strings.Join(names, " and "), format[0])
+	}
+	if o.count && len(format) > 0 && !o.cats && o.distinct == "" && o.countBy == "" {
+		return fmt.Errorf("-count can not be combined with %s", format[0])
+	}
+	if o.exact && (o.codePoint || o.codeRange != "") {
+		return fmt.Errorf("-exact can not be combined with -cp or -range")
+	}
+	return nil
+}
+
+// formats are the flags for how all the matches are printed.
+func (o *options) formats() []setFlag {
+	return []setFlag{
+		{"-json", o.json}, {"-json-lines", o.jsonLines}, {"-csv", o.csv}, {"-tsv", o.tsv},
+		{"-table", o.table}, {"-format", o.format != ""}, {"-cats", o.cats},
+		{"-distinct", o.distinct != ""}, {"-count-by", o.countBy != ""},
+	}
+}
+
+// details are the flags for what is printed of each match, which the
+// formats have their own way of.
+func (o *options) details() []setFlag {
+	return []setFlag{
+		{"-c", o.codes}, {"-dec", o.dec}, {"-width", o.width}, {"-bytes", o.bytes},
+		{"-utf16", o.utf16}, {"-entity", o.entity}, {"-escape", o.escape != ""},
+		{"-v", o.verbose}, {"-vv", o.veryVerbose}, {"-verbose", o.verbosity != 0},
+	}
+}
+
+// outputFlags returns the names of the formats, details and other flags of
+// how matches are printed that are set.
+func (o *options) outputFlags() []string {
+	flags := append(o.formats(), o.details()...)
+	flags = append(flags, setFlag{"-group", o.group}, setFlag{"-count", o.count})
+	return setNames(flags...)
+}
+
+func countTrue(bs ...bool) int {
+	var n int
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// splitList returns the comma separated items of s, without surrounding
+// white space.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// listFlag is a flag that can be given more than once, each value is added
+// to the list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// notCategory reports whether block is left out by -not-category.
+func (o *options) notCategory(block string) bool {
+	for _, name := range o.notCategories {
+		if strings.Contains(strings.ToLower(block), strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// char returns r as it is printed. Control and other non-printing
+// characters could ring the bell or garble the terminal, so they are shown
+// as their code point unless -raw is set.
+func (o *options) char(r rune) string {
+	if o.raw || unicode.IsGraphic(r) {
+		return string(r)
+	}
+	return fmt.Sprintf("%U", r)
+}
+
+// text returns c as it is printed, like char, or its whole sequence if it
+// is an emoji of more than one code point.
+func (o *options) text(c ucd.CodePoint) string {
+	if c.Sequence != "" {
+		return c.Sequence
+	}
+	return o.char(c.Chr)
+}
+
+// glyph is like text, but shows combining marks on a dotted circle, so they
+// are visible and do not combine with what is printed before them.
+func (o *options) glyph(c ucd.CodePoint) string {
+	if !o.raw && c.Sequence == "" && isCombining(c.Chr) {
+		return "\u25cc" + string(c.Chr)
+	}
+	return o.text(c)
+}
+
+// codePoints returns the U+XXXX code points of c, separated by spaces for
+// emoji sequences.
+func codePoints(c ucd.CodePoint) string {
+	var cps []string
+	for _, r := range c.String() {
+		cps = append(cps, fmt.Sprintf("%U", r))
+	}
+	return strings.Join(cps, " ")
+}
+
+func isCombining(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me)
+}
+
+func combiningLabel(r rune) string {
+	if isCombining(r) {
+		return " (combining)"
+	}
+	return ""
+}
+
+func printName(opts *options, r rune, c ucd.CodePoint) {
+	fmt.Fprintf(opts.stdout, "%s %U name=%q category=%q\n", opts.char(r), r, c.Desc, c.Category.Name)
+}
+
+func lookupNames(opts *options, chars string) error {
+	if chars == "" {
+		return fmt.Errorf("no characters to look up")
+	}
+	names, err := opts.loadNames()
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch %q: %w", unicodeIndex, err)
+		return err
+	}
+	for _, r := range chars {
+		c, ok := names.Lookup(r)
+		if !ok {
+			fmt.Fprintf(opts.stdout, "%s %U unnamed\n", opts.char(r), r)
+			continue
+		}
+		printName(opts, r, c)
+	}
+	return nil
+}
+
+// normForm returns the normalization form named name, so a decomposed "é"
+// is looked up as U+00E9 with NFC.
+func normForm(name string) (norm.Form, error) {
+	switch strings.ToUpper(name) {
+	case "NFC":
+		return norm.NFC, nil
+	case "NFD":
+		return norm.NFD, nil
+	case "NFKC":
+		return norm.NFKC, nil
+	case "NFKD":
+		return norm.NFKD, nil
+	}
+	return 0, fmt.Errorf("unknown normalization form %q, expected NFC, NFD, NFKC or NFKD", name)
+}
+
+// autoCodePoint returns query as a code point for -cp if it looks like one:
+// U+XXXX, 0xXXXX, or four to six hex digits of which at least one is a
+// decimal digit, so words like "face" are still searched.
+func autoCodePoint(query string) (string, bool) {
+	if len(query) > 2 && (strings.EqualFold(query[:2], "U+") || strings.EqualFold(query[:2], "0x")) {
+		_, err := strconv.ParseUint(query[2:], 16, 32)
+		return query, err == nil
+	}
+	if len(query) < 4 || len(query) > 6 || !strings.ContainsAny(query, "0123456789") {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(query, 16, 32); err != nil {
+		return "", false
+	}
+	return "U+" + query, true
+}
+
+func parseCodePoint(s string) (rune, error) {
+	digits, base := s, 10
+	if len(s) > 2 && (strings.EqualFold(s[:2], "U+") || strings.EqualFold(s[:2], "0x")) {
+		digits, base = s[2:], 16
+	}
+	i, err := strconv.ParseUint(digits, base, 32)
+	if err != nil || i > unicode.MaxRune {
+		return 0, fmt.Errorf("invalid code point %q", s)
+	}
+	return rune(i), nil
+}
+
+func parseRange(s string) (start, end rune, err error) {
+	sep := ".."
+	if !strings.Contains(s, sep) {
+		sep = "-"
+	}
+	parts := strings.SplitN(s, sep, 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q, expected start..end", s)
 	}
-	defer resp.Body.Close()
-	f, err = os.Create(cachePath)
+	if start, err = parseCodePoint(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseCodePoint(parts[1]); err != nil {
+		return 0, 0, err
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid range %q, start is after end", s)
+	}
+	return start, end, nil
+}
+
+// decompose prints the characters of the decomposition of the single
+// character chars.
+func decompose(opts *options, chars string) error {
+	if utf8.RuneCountInString(chars) != 1 {
+		return fmt.Errorf("-decompose needs a single character, got %q", chars)
+	}
+	r, _ := utf8.DecodeRuneInString(chars)
+	names, err := opts.loadNames()
 	if err != nil {
-		return nil, fmt.Errorf("could not create cache path %q: %w", cachePath, err)
+		return err
+	}
+	c, ok := names.Lookup(r)
+	if !ok {
+		return &noMatchError{search: chars, hint: fmt.Sprintf("%U is unassigned or excluded.", r)}
 	}
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return nil, fmt.Errorf("could not download %q to %q: %w", unicodeIndex, cachePath, err)
+	if c.Decomposition == nil {
+		return &noMatchError{search: chars, hint: fmt.Sprintf("%U has no decomposition.", r)}
 	}
-	f.Seek(0, 0)
-	return f, nil
+	if c.DecompositionTag != "" {
+		fmt.Fprintf(opts.stdout, "compatibility decomposition %s\n", c.DecompositionTag)
+	}
+	for _, d := range c.Decomposition {
+		dc, ok := names.Lookup(d)
+		if !ok {
+			fmt.Fprintf(opts.stdout, "%s %U unnamed\n", opts.char(d), d)
+			continue
+		}
+		printName(opts, d, dc)
+	}
+	return nil
 }
 
-type Category struct {
-	Name        string
-	Start, End  string
-	Description string
+// near prints the characters that can be confused with the single character
+// chars, with the script they are from.
+func near(opts *options, chars string) error {
+	if utf8.RuneCountInString(chars) != 1 {
+		return fmt.Errorf("-near needs a single character, got %q", chars)
+	}
+	r, _ := utf8.DecodeRuneInString(chars)
+	confusables, err := opts.cache.Confusables(r)
+	if err != nil {
+		return err
+	}
+	if len(confusables) == 0 {
+		return &noMatchError{search: chars, hint: "It has no known confusables."}
+	}
+	names, err := opts.loadNames()
+	if err != nil {
+		return err
+	}
+	for _, n := range confusables {
+		name := "unnamed"
+		if c, ok := names.Lookup(n); ok {
+			name = fmt.Sprintf("name=%q", c.Desc)
+		}
+		script, err := opts.cache.Script(n)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.stdout, "%s %U %s script=%q\n", opts.char(n), n, name, script)
+	}
+	return nil
 }
 
-type CodePoint struct {
-	Chr         rune
-	Desc        string
-	FullDesc    []string
-	Category    Category
-	Subcategory string
+// loadNames returns the entries of the NamesList by character, loading them
+// the first time.
+func (o *options) loadNames() (ucd.Names, error) {
+	if o.names == nil {
+		names, err := o.cache.LoadNames()
+		if err != nil {
+			return nil, err
+		}
+		o.names = names
+	}
+	return o.names, nil
 }
 
-func errorf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, args...)
+// decomposition returns the decomposition of c with the names of its
+// characters, like "latin small letter e + combining acute accent".
+func (o *options) decomposition(c ucd.CodePoint) string {
+	var parts []string
+	names, err := o.loadNames()
+	for _, d := range c.Decomposition {
+		if dc, ok := names.Lookup(d); ok && err == nil {
+			parts = append(parts, dc.Desc)
+		} else {
+			parts = append(parts, fmt.Sprintf("%U", d))
+		}
+	}
+	s := strings.Join(parts, " + ")
+	if c.DecompositionTag != "" {
+		s = c.DecompositionTag + " " + s
+	}
+	return s
 }
 
-func searchIndex(search string) (cp []CodePoint, err error) {
-	search = strings.ToLower(search)
-	f, err := fetchUnicodeURL(unicodeIndex)
+func lookupCodePoint(opts *options, s string) error {
+	r, err := parseCodePoint(s)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if !utf8.ValidRune(r) {
+		return fmt.Errorf("%U is a surrogate, not a character", r)
+	}
+	names, err := opts.loadNames()
+	if err != nil {
+		return err
+	}
+	c, ok := names.Lookup(r)
+	if !ok {
+		return &noMatchError{search: s, hint: fmt.Sprintf("%U is unassigned or excluded.", r)}
+	}
+	printName(opts, r, c)
+	return nil
+}
+
+// sortOrder returns the less function for sorting matches by field, or nil
+// to keep them in file order.
+func sortOrder(field string) (func(a, b ucd.CodePoint) bool, error) {
+	switch field {
+	case "":
+		return nil, nil
+	case "codepoint":
+		return func(a, b ucd.CodePoint) bool { return a.Chr < b.Chr }, nil
+	case "name":
+		return func(a, b ucd.CodePoint) bool { return a.Desc < b.Desc }, nil
+	case "category":
+		return func(a, b ucd.CodePoint) bool { return a.Category.Name < b.Category.Name }, nil
+	}
+	return nil, fmt.Errorf("invalid sort field %q, expected codepoint, name or category", field)
+}
+
+// utf8Hex returns the UTF-8 encoding of r as space separated hex bytes.
+func utf8Hex(r rune) string {
+	return fmt.Sprintf("% x", string(r))
+}
+
+// utf16Hex returns the UTF-16 encoding of r as space separated hex code units.
+func utf16Hex(r rune) string {
+	var units []string
+	for _, u := range utf16.Encode([]rune{r}) {
+		units = append(units, fmt.Sprintf("%04x", u))
+	}
+	return strings.Join(units, " ")
+}
+
+// entities returns the decimal and hex HTML character references of r, and
+// its named reference if it has one.
+func entities(r rune) string {
+	refs := fmt.Sprintf("&#%d; &#x%04x;", r, r)
+	if name, ok := htmlEntities[r]; ok {
+		refs += " &" + name + ";"
+	}
+	return refs
+}
+
+func printMatches(opts *options, cp []ucd.CodePoint) error {
+	if opts.json {
+		if cp == nil {
+			cp = []ucd.CodePoint{}
+		}
+		enc := json.NewEncoder(opts.stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cp)
 	}
-	defer f.Close()
-	buf := bufio.NewScanner(f)
-	for buf.Scan() {
-		parts := strings.Split(buf.Text(), "\t")
-		if len(parts) != 2 {
-			errorf("invalid format, expected 2 fields, got %d\n", len(parts))
+	if opts.csv || opts.tsv {
+		w := csv.NewWriter(opts.stdout)
+		if opts.tsv {
+			w.Comma = '\t'
+		}
+		w.Write([]string{"codepoint", "char", "name", "category", "subcategory"})
+		for _, c := range cp {
+			w.Write([]string{fmt.Sprintf("%U", c.Chr), opts.text(c), c.Desc, c.Category.Name, c.Subcategory})
 		}
-		if strings.Contains(strings.ToLower(parts[0]), search) {
-			chr, err := strconv.ParseInt(parts[1], 16, 32)
-			if err != nil {
-				errorf("invalid rune %q: %s", parts[1], err)
+		w.Flush()
+		return w.Error()
+	}
+	if opts.table {
+		return printTable(opts, cp)
+	}
+	if opts.group {
+		for i, cat := range categoryNames(cp) {
+			if i > 0 {
+				fmt.Fprintln(opts.stdout)
+			}
+			fmt.Fprintln(opts.stdout, opts.paint(colorCategory, cat))
+			for _, c := range cp {
+				if c.Category.Name != cat {
+					continue
+				}
+				if err := printMatch(opts, c); err != nil {
+					return err
+				}
 			}
-			cp = append(cp, CodePoint{rune(chr), parts[0], nil, Category{}, ""})
 		}
+		return nil
+	}
+	for _, c := range cp {
+		if err := printMatch(opts, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// categoryNames returns the sorted names of the blocks of cp.
+func categoryNames(cp []ucd.CodePoint) []string {
+	set := make(map[string]struct{})
+	var cats []string
+	for _, c := range cp {
+		if _, ok := set[c.Category.Name]; ok {
+			continue
+		}
+		set[c.Category.Name] = struct{}{}
+		cats = append(cats, c.Category.Name)
+	}
+	sort.Strings(cats)
+	return cats
+}
+
+// printCategories prints the blocks of cp with their range and the number
+// of matches in them.
+func printCategories(opts *options, cp []ucd.CodePoint) error {
+	counts := make(map[string]int)
+	var cats []ucd.Category
+	for _, c := range cp {
+		if counts[c.Category.Name] == 0 {
+			cats = append(cats, c.Category)
+		}
+		counts[c.Category.Name]++
+	}
+	if opts.count {
+		fmt.Fprintln(opts.stdout, len(cats))
+		return nil
+	}
+	if opts.sortBy == "codepoint" {
+		sort.SliceStable(cats, func(i, j int) bool {
+			return blockStart(cats[i]) < blockStart(cats[j])
+		})
+	} else {
+		sort.SliceStable(cats, func(i, j int) bool {
+			return cats[i].Name < cats[j].Name
+		})
+	}
+	for _, cat := range cats {
+		matches := "matches"
+		if counts[cat.Name] == 1 {
+			matches = "match"
+		}
+		if cat.Start == "" {
+			// Emoji groups have no range.
+			fmt.Fprintf(opts.stdout, "%s: %d %s\n", cat.Name, counts[cat.Name], matches)
+			continue
+		}
+		fmt.Fprintf(opts.stdout, "%s (U+%s..U+%s): %d %s\n", cat.Name, cat.Start, cat.End, counts[cat.Name], matches)
+	}
+	return nil
+}
+
+// listBlocks prints the name and range of every block.
+func listBlocks(opts *options) error {
+	blocks, err := opts.cache.Blocks()
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		fmt.Fprintf(opts.stdout, "%s (U+%s..U+%s)\n", b.Name, b.Start, b.End)
+	}
+	return nil
+}
+
+// fieldValue returns the function that returns the field of -distinct or
+// -count-by of a match.
+func fieldValue(field string) (func(ucd.CodePoint) string, error) {
+	switch field {
+	case "category":
+		return func(c ucd.CodePoint) string { return c.Category.Name }, nil
+	case "subcategory":
+		return func(c ucd.CodePoint) string { return c.Subcategory }, nil
+	case "script":
+		return func(c ucd.CodePoint) string { return c.Script }, nil
+	case "gc":
+		return func(c ucd.CodePoint) string { return c.GeneralCategory }, nil
+	case "age":
+		return func(c ucd.CodePoint) string { return c.Age }, nil
+	case "plane":
+		return func(c ucd.CodePoint) string { return strconv.Itoa(int(c.Chr >> 16)) }, nil
+	}
+	return nil, fmt.Errorf("invalid field %q, expected category, subcategory, script, gc, age or plane", field)
+}
+
+// properties returns the properties of other UCD files the output needs,
+// all of them for -verbose 3 or the one of the field of -distinct or
+// -count-by.
+func (o *options) properties() ucd.Property {
+	if o.verbosity >= 3 {
+		return ucd.AllProps
+	}
+	switch o.field() {
+	case "gc":
+		return ucd.PropUnicodeData
+	case "script":
+		return ucd.PropScript
+	case "age":
+		return ucd.PropAge
+	}
+	return 0
+}
+
+// field returns the field of -distinct or -count-by.
+func (o *options) field() string {
+	if o.countBy != "" {
+		return o.countBy
+	}
+	return o.distinct
+}
+
+// printDistinct prints the distinct values of cp returned by value, sorted,
+// with the number of matches that have them. With -count-by the values with
+// the most matches come first.
+func printDistinct(opts *options, cp []ucd.CodePoint, value func(ucd.CodePoint) string) error {
+	counts := make(map[string]int)
+	var values []string
+	for _, c := range cp {
+		v := value(c)
+		if counts[v] == 0 {
+			values = append(values, v)
+		}
+		counts[v]++
+	}
+	if opts.count {
+		fmt.Fprintln(opts.stdout, len(values))
+		return nil
+	}
+	sort.Strings(values)
+	if opts.countBy != "" {
+		sort.SliceStable(values, func(i, j int) bool {
+			return counts[values[i]] > counts[values[j]]
+		})
+	}
+	for _, v := range values {
+		matches := "matches"
+		if counts[v] == 1 {
+			matches = "match"
+		}
+		name := v
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(opts.stdout, "%s: %d %s\n", name, counts[v], matches)
+	}
+	return nil
+}
+
+// blockStart returns the first code point of the range of cat, or -1 if it
+// has none.
+func blockStart(cat ucd.Category) rune {
+	i, err := strconv.ParseUint(cat.Start, 16, 32)
+	if err != nil {
+		return -1
 	}
-	return cp, nil
+	return rune(i)
 }
 
-func matchAll(target []string, query string) bool {
-	q := strings.Fields(query)
-	for _, part := range q {
-		var match bool
-		for _, t := range target {
-			if strings.Contains(t, part) {
-				match = true
-				break
+// printContext prints the matches of cp with opts.context entries before
+// and after each of them in code point order, marking the matches with "> ".
+// Groups of entries that are not adjacent are separated by "--", as grep
+// does.
+func printContext(opts *options, cp []ucd.CodePoint) error {
+	all, err := opts.cache.SearchWith("", ucd.Options{CaseSensitive: opts.caseSens})
+	if err != nil {
+		return err
+	}
+	matched := make(map[rune]bool, len(cp))
+	var idx []int
+	for _, c := range cp {
+		i := sort.Search(len(all), func(i int) bool { return all[i].Chr >= c.Chr })
+		if i < len(all) && all[i].Chr == c.Chr {
+			matched[c.Chr] = true
+			idx = append(idx, i)
+		}
+	}
+	sort.Ints(idx)
+	// next is the first entry of all that has not been printed.
+	var next int
+	for k, i := range idx {
+		from, to := i-opts.context, i+opts.context
+		if from < next {
+			from = next
+		}
+		if to >= len(all) {
+			to = len(all) - 1
+		}
+		if k > 0 && from > next {
+			fmt.Fprintln(opts.stdout, "--")
+		}
+		for j := from; j <= to; j++ {
+			if matched[all[j].Chr] {
+				fmt.Fprint(opts.stdout, "> ")
+			} else {
+				fmt.Fprint(opts.stdout, "  ")
+			}
+			if err := printMatch(opts, all[j]); err != nil {
+				return err
 			}
 		}
-		if !match {
-			return false
+		if to >= next {
+			next = to + 1
 		}
 	}
-	return true
+	return nil
 }
 
-func searchNamesList(search string) (cp []CodePoint, err error) {
-	search = strings.ToLower(search)
-	var schr string
-	var lineNr int
-	var ccat Category
-	var cscat string
-	matcher := func(desc []string) {
-		if search == "" || matchAll(desc, search) || matchAll([]string{strings.ToLower(ccat.Name), strings.ToLower(cscat)}, search) {
-			i, err := strconv.ParseInt(schr, 16, 32)
-			if err != nil {
-				errorf("invalid rune %q: %s (set on line: %d)", schr, err, lineNr)
-				return
+// parseFormat parses the template of -format, and runs it once so errors
+// like unknown fields are reported before the search.
+func parseFormat(opts *options) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"char": opts.char,
+		"text": opts.text,
+	}).Parse(opts.format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -format: %w", err)
+	}
+	return tmpl, nil
+}
+
+// printEncodings prints c with each of the representations asked for,
+// labeled as with -vv, for when more than one is.
+func printEncodings(opts *options, c ucd.CodePoint) {
+	fmt.Fprint(opts.stdout, opts.text(c))
+	if opts.codes {
+		fmt.Fprintf(opts.stdout, " cp=%q", codePoints(c))
+	}
+	if opts.dec {
+		fmt.Fprintf(opts.stdout, " dec=%d", c.Chr)
+	}
+	if opts.width {
+		fmt.Fprintf(opts.stdout, " width=%d", displayWidth(c.Chr))
+	}
+	if opts.bytes {
+		fmt.Fprintf(opts.stdout, " utf8=%q", utf8Hex(c.Chr))
+	}
+	if opts.utf16 {
+		fmt.Fprintf(opts.stdout, " utf16=%q", utf16Hex(c.Chr))
+	}
+	if opts.entity {
+		fmt.Fprintf(opts.stdout, " entity=%q", entities(c.Chr))
+	}
+	if opts.escape != "" {
+		e, _ := escape(opts.escape, c.String())
+		fmt.Fprintf(opts.stdout, " escape=%s", e)
+	}
+	fmt.Fprintln(opts.stdout)
+}
+
+// printMatch prints c as opts asks for. A -format template can fail on some
+// characters only, like {{index .Aliases 0}} on one without aliases.
+func printMatch(opts *options, c ucd.CodePoint) error {
+	if opts.tmpl != nil {
+		if err := opts.tmpl.Execute(opts.stdout, c); err != nil {
+			fmt.Fprintln(opts.stdout)
+			return fmt.Errorf("could not format %U: %w", c.Chr, err)
+		}
+		fmt.Fprintln(opts.stdout)
+		return nil
+	}
+	if opts.jsonLines {
+		enc := json.NewEncoder(opts.stdout)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(c); err != nil {
+			return fmt.Errorf("could not encode %U: %w", c.Chr, err)
+		}
+		return nil
+	}
+	if opts.verbosity == 0 && countTrue(opts.codes, opts.dec, opts.width, opts.bytes, opts.utf16, opts.entity, opts.escape != "") > 1 {
+		printEncodings(opts, c)
+		return nil
+	}
+	if opts.codes {
+		fmt.Fprintln(opts.stdout, codePoints(c))
+		return nil
+	}
+	if opts.verbosity == 1 {
+		fmt.Fprintf(opts.stdout, "%s %s%s", opts.paint(colorChar, opts.glyph(c)), opts.paint(colorName, c.Desc), combiningLabel(c.Chr))
+		printDetails(opts, c)
+		fmt.Fprintln(opts.stdout)
+		return nil
+	}
+	if opts.verbosity >= 2 {
+		fmt.Fprintf(opts.stdout, "%s name=%s category=%s subcategory=%s from=%q to=%q",
+			opts.paint(colorChar, opts.glyph(c)), opts.paint(colorName, strconv.Quote(c.Desc)),
+			opts.paint(colorCategory, strconv.Quote(c.Category.Name)), opts.paint(colorCategory, strconv.Quote(c.Subcategory)),
+			c.Category.Start, c.Category.End)
+		if opts.verbosity >= 3 {
+			for _, a := range []struct {
+				name  string
+				lines []string
+			}{{"aliases", c.Aliases}, {"comments", c.Comments}, {"xrefs", c.CrossRefs}} {
+				if len(a.lines) > 0 {
+					fmt.Fprintf(opts.stdout, " %s=%q", a.name, strings.Join(a.lines, "; "))
+				}
+			}
+			if c.GeneralCategory != "" {
+				fmt.Fprintf(opts.stdout, " gc=%q bidi=%q", c.GeneralCategory, c.BidiClass)
+			}
+			if c.Script != "" {
+				fmt.Fprintf(opts.stdout, " script=%q", c.Script)
+			}
+			if c.Age != "" {
+				fmt.Fprintf(opts.stdout, " age=%q", c.Age)
+			}
+			if c.NumericValue != "" {
+				fmt.Fprintf(opts.stdout, " numeric=%q", c.NumericValue)
+			}
+			if isCombining(c.Chr) {
+				fmt.Fprint(opts.stdout, " combining=true")
+			}
+			if c.Decomposition != nil {
+				fmt.Fprintf(opts.stdout, " decomposition=%q", opts.decomposition(c))
+			}
+			if c.Category.Description != "" {
+				fmt.Fprintf(opts.stdout, " description=%q", c.Category.Description)
 			}
-			cp = append(cp, CodePoint{rune(i), desc[0], desc, ccat, cscat})
 		}
+		printDetails(opts, c)
+		fmt.Fprintln(opts.stdout)
+		return nil
+	}
+	if opts.dec {
+		fmt.Fprintf(opts.stdout, "%d\n", c.Chr)
+		return nil
+	}
+	if opts.width {
+		fmt.Fprintf(opts.stdout, "%s %d\n", opts.text(c), displayWidth(c.Chr))
+		return nil
+	}
+	if opts.entity {
+		fmt.Fprintf(opts.stdout, "%s %s\n", opts.text(c), entities(c.Chr))
+		return nil
+	}
+	if opts.escape != "" {
+		e, _ := escape(opts.escape, c.String())
+		fmt.Fprintf(opts.stdout, "%s %s\n", opts.text(c), e)
+		return nil
 	}
-	f, err := fetchUnicodeURL(unicodeNamesList)
+	if opts.utf16 {
+		units := "single unit"
+		if c.Chr > 0xFFFF {
+			units = "surrogate pair"
+		}
+		fmt.Fprintf(opts.stdout, "%s %s (%s)\n", opts.text(c), utf16Hex(c.Chr), units)
+		return nil
+	}
+	if opts.bytes {
+		fmt.Fprintf(opts.stdout, "%s %x -> %s\n", opts.text(c), c.Chr, utf8Hex(c.Chr))
+		return nil
+	}
+	fmt.Fprintln(opts.stdout, opts.text(c))
+	return nil
+}
+
+// printDetails prints the fields of the details flags of c, like dec=9829,
+// after the name of the verbose output.
+func printDetails(opts *options, c ucd.CodePoint) {
+	if opts.dec {
+		fmt.Fprintf(opts.stdout, " dec=%d", c.Chr)
+	}
+	if opts.width {
+		fmt.Fprintf(opts.stdout, " width=%d", displayWidth(c.Chr))
+	}
+	if opts.bytes {
+		fmt.Fprintf(opts.stdout, " utf8=%q", utf8Hex(c.Chr))
+	}
+	if opts.utf16 {
+		fmt.Fprintf(opts.stdout, " utf16=%q", utf16Hex(c.Chr))
+	}
+	if opts.entity {
+		fmt.Fprintf(opts.stdout, " entity=%q", entities(c.Chr))
+	}
+	if opts.escape != "" {
+		e, _ := escape(opts.escape, c.String())
+		fmt.Fprintf(opts.stdout, " escape=%s", e)
+	}
+}
+
+func cacheInfo(opts *options) error {
+	dir, err := opts.cache.Dir()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer f.Close()
-	rdr := bufio.NewScanner(f)
-	desc := make([]string, 0, 5)
-	var category Category
-	var subcategory string
-	for rdr.Scan() {
-		lineNr++
-		line := rdr.Text()
-		if strings.HasPrefix(line, "@\t\t") {
-			subcategory = line[3:]
-			continue
+	files, err := opts.cache.Files()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.stdout, "cache dir: %s\n", dir)
+	if len(files) == 0 {
+		fmt.Fprintln(opts.stdout, "no cached files")
+	}
+	for _, path := range files {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("could not stat %q: %w", path, err)
 		}
-		if strings.HasPrefix(line, "@@\t") {
-			parts := strings.Split(line, "\t")
-			category = Category{Name: parts[2], Start: parts[1], End: parts[3]}
-			continue
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
 		}
-		if strings.HasPrefix(line, "@+\t\t") {
-			category.Description = line[4:]
-			continue
+		fmt.Fprintf(opts.stdout, "%-28s %10d bytes  %s old\n", rel, fi.Size(), age(time.Since(fi.ModTime())))
+	}
+	return nil
+}
+
+// age formats d in the largest unit that is at least two of it.
+func age(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	return fmt.Sprintf("%d minutes", d/time.Minute)
+}
+
+func clearCache(opts *options) error {
+	removed, err := opts.cache.Clear()
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		dir, _ := opts.cache.Dir()
+		opts.errorf("nothing to remove, the cache at %q is empty\n", dir)
+	}
+	for _, path := range removed {
+		fmt.Fprintf(opts.stdout, "removed %s\n", path)
+	}
+	return nil
+}
+
+// searchStdin prints the matches of each line of stdin under a line with
+// the query, like searchQuery prints those of the query of the command line.
+// The entries are only searched for once, and a line that fails, like an
+// invalid code point, is reported in its place.
+func searchStdin(ctx context.Context, opts *options, search searchFunc, searchOpts ucd.Options) error {
+	var all []ucd.CodePoint
+	err := search(ctx, "", searchOpts, func(c ucd.CodePoint) error {
+		all = append(all, c)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	names := make(ucd.Names, len(all))
+	for _, c := range all {
+		names[c.Chr] = c
+	}
+	filter := func(ctx context.Context, query string, searchOpts ucd.Options, fn func(ucd.CodePoint) error) error {
+		cp, err := stdinQuery(query, all, names, searchOpts)
+		if err != nil {
+			return err
 		}
-		if strings.HasPrefix(line, ";") || strings.HasPrefix(line, "@") || strings.HasPrefix(line, "\t\t") {
-			continue
+		for _, c := range cp {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(c); err != nil {
+				return err
+			}
 		}
-		if excludeCategory(category.Name) {
-			continue
+		return nil
+	}
+	// The lines are read in the background, so a Ctrl-C while waiting for
+	// one stops right away.
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		s := bufio.NewScanner(opts.input)
+		for s.Scan() {
+			select {
+			case lines <- s.Text():
+			case <-ctx.Done():
+				return
+			}
 		}
-		parts := strings.Split(line, "\t")
-		if len(parts) != 2 {
-			errorf("invalid format, expected 2 fields, got %d: %s\n", len(parts), line)
+		readErr <- s.Err()
+	}()
+	for {
+		var line string
+		var ok bool
+		select {
+		case line, ok = <-lines:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		query := strings.TrimSpace(line)
+		if query == "" {
 			continue
 		}
-		if parts[0] != "" {
-			if schr != "" {
-				matcher(desc)
-			}
-			schr = parts[0]
-			desc = desc[0:0]
-			ccat = category
-			cscat = subcategory
+		fmt.Fprintf(opts.stdout, "%s:\n", query)
+		err := searchQuery(ctx, opts, query, filter, searchOpts)
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-		desc = append(desc, strings.ToLower(parts[1]))
+		var nerr *noMatchError
+		if errors.As(err, &nerr) {
+			err = fmt.Errorf("Not found")
+		}
+		if err != nil {
+			fmt.Fprintf(opts.stdout, "  %s\n", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := <-readErr; err != nil {
+		return fmt.Errorf("could not read stdin: %w", err)
 	}
-	matcher(desc)
-	return cp, nil
+	return nil
 }
 
-func excludeCategory(group string) bool {
-	if group == "Sutton SignWriting" || group == "Runic" || group == "Coptic" {
-		return true
+// stdinQuery returns the entries of all that match query, or the one of
+// names if query is a code point.
+func stdinQuery(query string, all []ucd.CodePoint, names ucd.Names, searchOpts ucd.Options) ([]ucd.CodePoint, error) {
+	if len(query) > 2 && (strings.EqualFold(query[:2], "U+") || strings.EqualFold(query[:2], "0x")) {
+		r, err := parseCodePoint(query)
+		if err != nil {
+			return nil, err
+		}
+		c, ok := names.Lookup(r)
+		if !ok {
+			return nil, fmt.Errorf("%U is unassigned or excluded", r)
+		}
+		return []ucd.CodePoint{c}, nil
 	}
-	return false
+	return ucd.Filter(all, query, searchOpts)
 }
 
-func run() error {
-	var args []string
-	flags := make(map[string]bool)
-	for _, arg := range os.Args {
-		if strings.HasPrefix(arg, "-") {
-			flags[strings.TrimLeft(arg, "-")] = true
-			continue
+// orList returns the words joined by commas, with "or" before the last.
+func orList(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+// noMatchError is returned by run when the search matched nothing, which
+// exits with status 1 instead of the 2 of other errors. Its message
+// suggests how to widen the search.
+type noMatchError struct {
+	search string
+	hint   string
+	// silent is set when the output is for programs, like an empty JSON
+	// array or a count of 0, which already says nothing matched, so the
+	// message is not printed.
+	silent bool
+}
+
+func (e *noMatchError) Error() string {
+	if e.search == "" {
+		return "No characters matched. " + e.hint
+	}
+	return fmt.Sprintf("No characters matched %q. %s", e.search, e.hint)
+}
+
+func noMatch(opts *options, search string) error {
+	var filters []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"-category", opts.category != ""},
+		{"-not-category", len(opts.notCategories) > 0},
+		{"-subcategory", opts.subcategory != ""},
+		{"-gc", opts.gc != ""},
+		{"-script", opts.script != ""},
+		{"-since", opts.since != ""},
+		{"-range", opts.codeRange != ""},
+		{"-ascii", opts.ascii},
+		{"-bmp", opts.bmp},
+		{"-plane", opts.plane != -1},
+	} {
+		if f.set {
+			filters = append(filters, f.name)
 		}
-		args = append(args, arg)
 	}
-	var search string
-	if len(args) >= 2 {
-		search = strings.Join(args[1:], " ")
+	var hint string
+	switch {
+	case opts.exact:
+		hint = "Try without -exact to match part of the name."
+	case opts.regexp && !opts.any:
+		hint = "Check the regular expression, it is only matched against the name. Try -any to also match aliases, comments and block names."
+	case opts.regexp:
+		hint = "Check the regular expression, with -any it is matched against each line of the entry as written in the NamesList, like \"= alias\", and the names of its block and subcategory."
+	case opts.word:
+		hint = "Try without -word to also match part of a word."
+	case opts.prefix:
+		hint = "Try without -prefix to match anywhere in the name."
+	case len(filters) > 0:
+		hint = "Try without " + orList(filters) + "."
+	case len(strings.Fields(search)) > 1:
+		hint = "Try fewer or shorter terms."
+	case !opts.any:
+		hint = "Try -any to also search aliases, comments and block names."
+	case !opts.fuzzy:
+		hint = "Try -fuzzy to allow typos."
+	default:
+		hint = "Try a shorter term."
+	}
+	if opts.emoji {
+		hint += " Without -emoji all characters are searched."
+	}
+	silent := opts.json || opts.jsonLines || opts.csv || opts.tsv || opts.count
+	return &noMatchError{search, hint, silent}
+}
+
+// printStats prints the time the search took, split into downloading,
+// parsing and searching, with the lines parsed and the number of matches.
+func printStats(opts *options, elapsed time.Duration, matches int) {
+	st := opts.cache.Stats()
+	search := elapsed - st.Download - st.Parse
+	if search < 0 {
+		search = 0
+	}
+	fmt.Fprintf(opts.stderr, "download=%s parse=%s index=%t search=%s lines=%d matches=%d\n",
+		st.Download.Round(time.Microsecond), st.Parse.Round(time.Microsecond), st.Index,
+		search.Round(time.Microsecond), st.Lines, matches)
+}
+
+// errFirstFound stops the search once -first has printed a match.
+var errFirstFound = errors.New("first match found")
+
+// run runs unifind with the command line arguments args, reading stdin for
+// -stdin and -i, printing the output to stdout and messages to stderr.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	opts, args, err := parseFlags(args, stdout, stderr)
+	if err == flag.ErrHelp {
+		return nil
 	}
-	cp, err := searchNamesList(search)
 	if err != nil {
 		return err
 	}
-	if flags["cats"] {
-		set := make(map[string]struct{})
-		var cats []string
-		for _, c := range cp {
-			if _, ok := set[c.Category.Name]; ok {
-				continue
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if opts.verbosity < 0 || opts.verbosity > 3 {
+		return fmt.Errorf("invalid verbose level %d, expected 1 to 3", opts.verbosity)
+	}
+	if opts.veryVerbose && opts.verbosity < 2 {
+		opts.verbosity = 2
+	}
+	if opts.verbose && opts.verbosity < 1 {
+		opts.verbosity = 1
+	}
+	opts.input, opts.stdout, opts.stderr = stdin, stdout, stderr
+	if opts.out != "" {
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %w", err)
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("could not write output file: %w", cerr)
 			}
-			set[c.Category.Name] = struct{}{}
-			cats = append(cats, c.Category.Name)
+		}()
+		opts.stdout = f
+	}
+	colored, err := useColor(opts.color, opts.stdout)
+	if err != nil {
+		return err
+	}
+	opts.colored = colored
+	opts.cache = &ucd.Cache{
+		Offline:    opts.offline,
+		Refresh:    opts.refresh,
+		MaxAge:     time.Duration(opts.maxAge) * 24 * time.Hour,
+		Timeout:    opts.timeout,
+		BaseDir:    opts.cacheDir,
+		Strict:     opts.strict,
+		Embedded:   opts.embedded,
+		Properties: opts.properties(),
+		NamesList:  opts.namesList,
+		Index:      opts.index,
+		Version:    opts.version,
+		Exclude:    splitList(opts.exclude),
+	}
+	if !opts.quiet {
+		opts.cache.Progress = stderr
+		opts.cache.Warnings = stderr
+	}
+	if opts.includeAll {
+		opts.cache.Exclude = nil
+	}
+	if opts.manpage {
+		fmt.Fprint(opts.stdout, manpage())
+		return nil
+	}
+	if opts.completion != "" {
+		script, err := completion(opts.completion)
+		if err != nil {
+			return err
 		}
-		sort.Slice(cats, func(i, j int) bool {
-			return cats[i] < cats[j]
-		})
-		for _, cat := range cats {
-			fmt.Println(cat)
+		fmt.Fprint(opts.stdout, script)
+		return nil
+	}
+	if opts.cacheInfo {
+		return cacheInfo(opts)
+	}
+	if opts.clearCache {
+		return clearCache(opts)
+	}
+	if opts.blocks {
+		return listBlocks(opts)
+	}
+	if opts.name || opts.paste {
+		form, err := normForm(opts.nf)
+		if err != nil {
+			return err
+		}
+		chars := strings.Join(args, "")
+		if opts.paste {
+			if chars, err = pasteFromClipboard(); err != nil {
+				return fmt.Errorf("could not read the clipboard: %w", err)
+			}
+			if chars == "" {
+				return fmt.Errorf("the clipboard is empty")
+			}
+		}
+		return lookupNames(opts, form.String(chars))
+	}
+	if opts.decompose {
+		return decompose(opts, strings.Join(args, ""))
+	}
+	if opts.near {
+		return near(opts, strings.Join(args, ""))
+	}
+	if opts.codePoint {
+		return lookupCodePoint(opts, strings.Join(args, ""))
+	}
+	if len(args) == 1 && !opts.noAutoCP && !opts.regexp {
+		if cp, ok := autoCodePoint(args[0]); ok {
+			if names := opts.outputFlags(); len(names) > 0 {
+				return fmt.Errorf("%s can not be combined with looking up the code point %s, add -no-autocp to search for it", strings.Join(names, " and "), args[0])
+			}
+			return lookupCodePoint(opts, cp)
+		}
+	}
+	query := strings.Join(args, " ")
+	mode, err := opts.mode()
+	if err != nil {
+		return err
+	}
+	searchOpts := ucd.Options{
+		CaseSensitive:   opts.caseSens,
+		Mode:            mode,
+		Category:        opts.category,
+		Subcategory:     opts.subcategory,
+		GeneralCategory: opts.gc,
+		Script:          opts.script,
+		AnyLine:         opts.any,
+		Since:           opts.since,
+	}
+	if opts.format != "" {
+		if opts.tmpl, err = parseFormat(opts); err != nil {
+			return err
+		}
+	}
+	if opts.escape != "" {
+		if _, err := escape(opts.escape, ""); err != nil {
+			return err
+		}
+	}
+	if opts.interactive {
+		return browse(opts, searchOpts)
+	}
+	if opts.less, err = sortOrder(opts.sortBy); err != nil {
+		return err
+	}
+	if opts.field() != "" {
+		if opts.value, err = fieldValue(opts.field()); err != nil {
+			return err
+		}
+	}
+	opts.start, opts.end = 0, unicode.MaxRune
+	if opts.codeRange != "" {
+		if opts.start, opts.end, err = parseRange(opts.codeRange); err != nil {
+			return err
+		}
+	}
+	if opts.ascii && opts.end > unicode.MaxASCII {
+		opts.end = unicode.MaxASCII
+	}
+	if opts.bmp && opts.end > 0xFFFF {
+		opts.end = 0xFFFF
+	}
+	if opts.plane != -1 {
+		if opts.plane < 0 || opts.plane > 16 {
+			return fmt.Errorf("invalid plane %d, expected 0 to 16", opts.plane)
+		}
+		if first := rune(opts.plane) << 16; opts.start < first {
+			opts.start = first
+		}
+		if last := rune(opts.plane)<<16 | 0xFFFF; opts.end > last {
+			opts.end = last
+		}
+	}
+	if opts.limit < 0 {
+		return fmt.Errorf("invalid limit %d", opts.limit)
+	}
+	if opts.context < 0 {
+		return fmt.Errorf("invalid context %d", opts.context)
+	}
+	if opts.first {
+		opts.limit = 1
+	}
+	search := opts.cache.SearchContext
+	if opts.emoji {
+		search = opts.cache.SearchEmojiContext
+	}
+	if opts.useIndex {
+		search = opts.cache.SearchIndexContext
+	}
+	// Ctrl-C stops a slow download or search, instead of the whole process
+	// with a half written -out file.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// A second Ctrl-C kills the process, in case something does not stop.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if opts.stdin {
+		return searchStdin(ctx, opts, search, searchOpts)
+	}
+	return searchQuery(ctx, opts, query, search, searchOpts)
+}
+
+// searchFunc is the signature of (*ucd.Cache).SearchContext.
+type searchFunc func(ctx context.Context, search string, opts ucd.Options, fn func(ucd.CodePoint) error) error
+
+// searchQuery prints the matches of query found by search, filtered,
+// sorted and formatted according to opts.
+func searchQuery(ctx context.Context, opts *options, query string, search searchFunc, searchOpts ucd.Options) error {
+	// Matches are printed as they are found, unless the output needs all
+	// of them first.
+	stream := !opts.cats && !opts.json && !opts.csv && !opts.tsv && !opts.table && !opts.group && opts.value == nil && !opts.copy && !opts.random && opts.less == nil && opts.context == 0
+	var cp []ucd.CodePoint
+	var total int
+	searchStart := time.Now()
+	err := search(ctx, query, searchOpts, func(c ucd.CodePoint) error {
+		if c.Chr < opts.start || c.Chr > opts.end || opts.notCategory(c.Category.Name) {
+			return nil
+		}
+		total++
+		if !stream {
+			cp = append(cp, c)
+		} else if !opts.count && (opts.limit == 0 || total <= opts.limit) {
+			if err := printMatch(opts, c); err != nil {
+				return err
+			}
+			if opts.first {
+				return errFirstFound
+			}
 		}
 		return nil
+	})
+	if err != nil && err != errFirstFound {
+		return err
 	}
-	for _, c := range cp {
-		if flags["c"] {
-			fmt.Printf("%U\n", c.Chr)
-			continue
+	if opts.stats {
+		printStats(opts, time.Since(searchStart), total)
+	}
+	if opts.cats {
+		return printCategories(opts, cp)
+	}
+	if opts.value != nil {
+		return printDistinct(opts, cp, opts.value)
+	}
+	if opts.count {
+		fmt.Fprintln(opts.stdout, total)
+		if total == 0 {
+			return noMatch(opts, query)
 		}
-		if flags["v"] {
-			fmt.Printf("%c %s\n", c.Chr, c.Desc)
-			continue
+		return nil
+	}
+	if opts.random && len(cp) > 0 {
+		seed := opts.seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
 		}
-		if flags["vv"] {
-			fmt.Printf("%c name=%q category=%q subcategory=%q from=%q to=%q\n",
-				c.Chr, c.Desc, c.Category.Name, c.Subcategory, c.Category.Start, c.Category.End)
-			continue
+		i := rand.New(rand.NewSource(seed)).Intn(len(cp))
+		cp = cp[i : i+1]
+		total = 1
+	}
+	if !stream {
+		if opts.less != nil {
+			sort.SliceStable(cp, func(i, j int) bool {
+				return opts.less(cp[i], cp[j])
+			})
+		}
+		if opts.limit > 0 && total > opts.limit {
+			cp = cp[:opts.limit]
+		}
+		if opts.context > 0 && !opts.json && !opts.jsonLines {
+			err = printContext(opts, cp)
+		} else {
+			err = printMatches(opts, cp)
+		}
+		if err != nil {
+			return err
 		}
-		fmt.Printf("%c\n", c.Chr)
 	}
-	if len(cp) == 0 {
-		return fmt.Errorf("Not found")
+	if opts.limit > 0 && total > opts.limit && !opts.first {
+		opts.errorf("showing %d of %d matches\n", opts.limit, total)
+	}
+	if total == 0 {
+		return noMatch(opts, query)
+	}
+	if opts.copy {
+		if len(cp) != 1 {
+			return fmt.Errorf("can not copy %d matches, narrow the search to a single character", len(cp))
+		}
+		if err := copyToClipboard(cp[0].String()); err != nil {
+			return fmt.Errorf("could not copy to clipboard: %w", err)
+		}
+		opts.errorf("copied %s %U to the clipboard\n", opts.text(cp[0]), cp[0].Chr)
 	}
 	return nil
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if err == errUsage {
+			os.Exit(2)
+		}
+		var nerr *noMatchError
+		if errors.As(err, &nerr) {
+			if !nerr.silent {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
+		}
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(2)
 	}
 }
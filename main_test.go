@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testNamesList = "ucd/testdata/NamesList.txt"
+
+// runTest runs unifind on the NamesList fixture with args and stdin, and
+// returns what it printed.
+func runTest(t *testing.T, stdin string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	args = append([]string{"-offline", "-cache-dir", t.TempDir(), "-namelist", testNamesList, "-color", "never"}, args...)
+	err = run(args, strings.NewReader(stdin), &out, &errOut)
+	return out.String(), errOut.String(), err
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		stdin string
+		args  []string
+		want  string
+	}{
+		{"search", "", []string{"arrow"}, "←\n→\n"},
+		{"verbose", "", []string{"-v", "rightwards"}, "→ rightwards arrow\n"},
+		{"verbose details", "", []string{"-v", "-dec", "-bytes", "rightwards"}, "→ rightwards arrow dec=8594 utf8=\"e2 86 92\"\n"},
+		{"very verbose details", "", []string{"-vv", "-dec", "-bytes", "rightwards"}, "→ name=\"rightwards arrow\" category=\"Arrows\" subcategory=\"Simple arrows\" from=\"2190\" to=\"21FF\" dec=8594 utf8=\"e2 86 92\"\n"},
+		{"code point", "", []string{"U+0041"}, "A U+0041 name=\"latin capital letter a\" category=\"C0 Controls and Basic Latin (Basic Latin)\"\n"},
+		{"stdin", "leftwards\nU+0021\n", []string{"-stdin"}, "leftwards:\n←\nU+0021:\n!\n"},
+		{"stdin not found", "nothing\nU+99999\n", []string{"-stdin"}, "nothing:\n  Not found\nU+99999:\n  U+99999 is unassigned or excluded\n"},
+		{"stdin first", "arrow\n", []string{"-stdin", "-first"}, "arrow:\n←\n"},
+		{"stdin sort", "r\n", []string{"-stdin", "-sort", "name", "-range", "U+2000..U+FFFF"}, "r:\n←\n→\n"},
+		{"stdin ascii", "a\n", []string{"-stdin", "-ascii", "-not-category", "controls"}, "a:\n  Not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, err := runTest(t, tt.stdin, tt.args...)
+			if err != nil {
+				t.Fatalf("got error %v, stderr %q", err, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("got %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunNoMatch(t *testing.T) {
+	stdout, stderr, err := runTest(t, "", "-quiet", "nothing")
+	if _, ok := err.(*noMatchError); !ok {
+		t.Fatalf("got error %v, want a noMatchError", err)
+	}
+	if stdout != "" || stderr != "" {
+		t.Errorf("got stdout %q and stderr %q with -quiet, want nothing", stdout, stderr)
+	}
+}
+
+func TestStdinInterrupt(t *testing.T) {
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Skip(err)
+	}
+	stdin, w := io.Pipe()
+	defer w.Close()
+	done := make(chan error, 1)
+	go func() {
+		var out bytes.Buffer
+		done <- run([]string{"-offline", "-cache-dir", t.TempDir(), "-namelist", testNamesList, "-stdin"}, stdin, &out, io.Discard)
+	}()
+	// Once the first line is read, run waits for the next one with Ctrl-C
+	// handled.
+	if _, err := io.WriteString(w, "arrow\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Skip(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting for stdin after Ctrl-C")
+	}
+}
+
+func TestRunIndex(t *testing.T) {
+	const index = "ucd/testdata/Index.txt"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// The NamesList only has factorial as an alias of U+0021.
+		{[]string{"factorial"}, ""},
+		{[]string{"-use-index", "-index", index, "factorial"}, "!\n"},
+		{[]string{"-use-index", "-index", index, "-v", "arrow"}, "← arrows\n→ rightwards arrow\n"},
+		{[]string{"-use-index", "-index", index, "-c", "function"}, "U+2192\n"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			stdout, _, err := runTest(t, "", tt.args...)
+			if _, ok := err.(*noMatchError); err != nil && !(ok && tt.want == "") {
+				t.Fatalf("got error %v", err)
+			}
+			if stdout != tt.want {
+				t.Errorf("got %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+	_, _, err := runTest(t, "", "-use-index", "-index", "missing/Index.txt", "arrow")
+	if _, ok := err.(*noMatchError); err == nil || ok {
+		t.Errorf("got error %v for a missing Index.txt, want one that exits with 2", err)
+	}
+}
+
+func TestRunFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"{{index .FullDesc 0}}", "leftwards arrow\nrightwards arrow\n"},
+		{"{{slice .Desc 0 4}}", "left\nrigh\n"},
+		{"{{char .Chr}} {{len .Aliases}}", "← 0\n→ 1\n"},
+	}
+	for _, tt := range tests {
+		stdout, stderr, err := runTest(t, "", "-format", tt.format, "arrow")
+		if err != nil {
+			t.Fatalf("%s: got error %v, stderr %q", tt.format, err, stderr)
+		}
+		if stdout != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, stdout, tt.want)
+		}
+	}
+	// Only U+2192 has an alias, so the template fails on U+2190.
+	_, _, err := runTest(t, "", "-format", "{{index .Aliases 0}}", "arrow")
+	if err == nil || !strings.Contains(err.Error(), "could not format U+2190") {
+		t.Errorf("got error %v, want the one of formatting U+2190", err)
+	}
+}
+
+func TestRunLookupNoMatch(t *testing.T) {
+	// Not finding a character exits with 1, like a search without
+	// matches, and a code point that is not one exits with 2.
+	for _, args := range [][]string{{"-cp", "U+0378"}, {"U+0378"}, {"-decompose", "A"}, {"-decompose", "é"}} {
+		_, _, err := runTest(t, "", args...)
+		if _, ok := err.(*noMatchError); !ok {
+			t.Errorf("%v: got error %v, want a noMatchError", args, err)
+		}
+	}
+	_, _, err := runTest(t, "", "-cp", "U+ZZZZ")
+	if _, ok := err.(*noMatchError); err == nil || ok {
+		t.Errorf("got error %v for an invalid code point, want one that exits with 2", err)
+	}
+}
+
+func TestValidateConflicts(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-first", "-limit", "5"}, "-first and -limit are mutually exclusive"},
+		{[]string{"-first", "-random"}, "-first and -random are mutually exclusive"},
+		{[]string{"-offline", "-refresh"}, "-offline and -refresh are mutually exclusive"},
+		{[]string{"-embedded", "-namelist", testNamesList}, "-embedded and -namelist are mutually exclusive"},
+		{[]string{"-sort", "name", "-fuzzy"}, "-sort and -fuzzy are mutually exclusive"},
+		{[]string{"-sort", "codepoint", "-prefix"}, "-sort and -prefix are mutually exclusive"},
+		{[]string{"-i", "-json"}, "-json can not be combined with -i"},
+		{[]string{"-i", "-csv"}, "-csv can not be combined with -i"},
+		{[]string{"-i", "-emoji"}, "-emoji can not be combined with -i"},
+		{[]string{"-stdin", "-json-lines"}, "-json-lines can not be combined with -stdin"},
+		{[]string{"-stdin", "-copy"}, "-copy can not be combined with -stdin"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			t.Setenv("UNIFIND_OFFLINE", "")
+			t.Setenv("UNIFIND_NAMESLIST", "")
+			opts, _, err := parseFlags(append(tt.args, "arrow"), io.Discard, io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := opts.validate(); err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRejectsOutputFlagsOfLookups(t *testing.T) {
+	tests := [][]string{
+		{"-name", "-json", "é"},
+		{"-cp", "-csv", "U+00E9"},
+		{"-blocks", "-count"},
+		{"-decompose", "-v", "é"},
+		{"-near", "-group", "A"},
+		{"-bytes", "U+0041"},
+	}
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			stdout, _, err := runTest(t, "", args...)
+			if err == nil || !strings.Contains(err.Error(), "can not be combined") {
+				t.Errorf("got error %v, want one about the flags that can not be combined", err)
+			}
+			if stdout != "" {
+				t.Errorf("got output %q, want none", stdout)
+			}
+		})
+	}
+}
+
+func TestNoMatchRegexHint(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-regex", "^z notation"}, "Try -any"},
+		{[]string{"-regex", "-any", "^nothing"}, "as written in the NamesList"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, _, err := runTest(t, "", tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want a hint with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoMatchSilentForPrograms(t *testing.T) {
+	tests := []struct {
+		flag   string
+		silent bool
+	}{
+		{"-json", true},
+		{"-json-lines", true},
+		{"-csv", true},
+		{"-tsv", true},
+		{"-count", true},
+		{"-table", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			_, _, err := runTest(t, "", tt.flag, "nothing")
+			nerr, ok := err.(*noMatchError)
+			if !ok {
+				t.Fatalf("got error %v, want a noMatchError", err)
+			}
+			if nerr.silent != tt.silent {
+				t.Errorf("got silent %t, want %t", nerr.silent, tt.silent)
+			}
+		})
+	}
+}
+
+func TestRunOutsideBMP(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "😀\n"},
+		{[]string{"-c"}, "U+1F600\n"},
+		{[]string{"-dec"}, "128512\n"},
+		{[]string{"-bytes"}, "😀 1f600 -> f0 9f 98 80\n"},
+		{[]string{"-utf16"}, "😀 d83d de00 (surrogate pair)\n"},
+		{[]string{"-entity"}, "😀 &#128512; &#x1f600;\n"},
+		{[]string{"-escape", "go"}, "😀 \\U0001f600\n"},
+		{[]string{"-escape", "json"}, "😀 \\ud83d\\ude00\n"},
+		{[]string{"-width"}, "😀 2\n"},
+		{[]string{"-v"}, "😀 grinning face\n"},
+		{[]string{"-vv"}, "😀 name=\"grinning face\" category=\"Emoticons\" subcategory=\"Faces\" from=\"1F600\" to=\"1F64F\"\n"},
+		{[]string{"-format", "{{printf \"%c %U\" .Chr .Chr}}"}, "😀 U+1F600\n"},
+		{[]string{"-json-lines"}, `{"chr":"😀","codepoint":"U+1F600","name":"GRINNING FACE","desc":"grinning face","full_desc":["grinning face"],"category":{"name":"Emoticons","start":"1F600","end":"1F64F","description":""},"subcategory":"Faces"}` + "\n"},
+		{[]string{"-json"}, `[
+  {
+    "chr": "😀",
+    "codepoint": "U+1F600",
+    "name": "GRINNING FACE",
+    "desc": "grinning face",
+    "full_desc": [
+      "grinning face"
+    ],
+    "category": {
+      "name": "Emoticons",
+      "start": "1F600",
+      "end": "1F64F",
+      "description": ""
+    },
+    "subcategory": "Faces"
+  }
+]
+`},
+		{[]string{"-csv"}, "codepoint,char,name,category,subcategory\nU+1F600,😀,grinning face,Emoticons,Faces\n"},
+		{[]string{"-tsv"}, "codepoint\tchar\tname\tcategory\tsubcategory\nU+1F600\t😀\tgrinning face\tEmoticons\tFaces\n"},
+		{[]string{"-table"}, "char | code point | name          | category\n😀   | U+1F600    | grinning face | Emoticons\n"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			stdout, stderr, err := runTest(t, "", append(tt.args, "grinning")...)
+			if err != nil {
+				t.Fatalf("got error %v, stderr %q", err, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("got %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+	for _, args := range [][]string{{"U+1F600"}, {"-cp", "0x1F600"}, {"-name", "😀"}} {
+		stdout, _, err := runTest(t, "", args...)
+		if want := "😀 U+1F600 name=\"grinning face\" category=\"Emoticons\"\n"; err != nil || stdout != want {
+			t.Errorf("%v: got %q and error %v, want %q", args, stdout, err, want)
+		}
+	}
+}
+
+func TestRunRejectsSurrogates(t *testing.T) {
+	for _, args := range [][]string{{"-cp", "U+D800"}, {"U+DFFF"}, {"-cp", "0xDBFF"}} {
+		_, _, err := runTest(t, "", args...)
+		if err == nil || !strings.Contains(err.Error(), "surrogate") {
+			t.Errorf("%v: got error %v, want one saying it is a surrogate", args, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// manpage returns the man page of unifind in roff, with the flags of
+// newFlagSet.
+func manpage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s 1\n.SH NAME\n%s \\- find Unicode characters by name\n", strings.ToUpper(appName), appName)
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n[\\fIflags\\fR] [\\fIquery\\fR ...]\n", appName)
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", roffEscape(querySyntax))
+	b.WriteString(".SH OPTIONS\n")
+	newFlagSet(&options{}).VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		fmt.Fprintf(&b, ".TP\n.B \\-%s", roffEscape(f.Name))
+		if name != "" {
+			fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(name))
+		}
+		b.WriteString("\n" + roffEscape(usage))
+		if !isBoolFlag(f) && f.DefValue != "" && f.DefValue != "0" {
+			fmt.Fprintf(&b, " (default %s)", roffEscape(f.DefValue))
+		}
+		b.WriteString("\n")
+	})
+	fmt.Fprintf(&b, ".SH EXIT STATUS\n%s\n.SH EXAMPLES\n", roffEscape(exitStatus))
+	for _, e := range examples {
+		fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", roffEscape(e.command), roffEscape(e.what))
+	}
+	return b.String()
+}
+
+// roffEscape escapes the backslashes and dashes of s, and the dots and
+// quotes that would start a request at the beginning of a line.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rafael-luigi-bekkema/unifind/ucd"
+)
+
+// printTable prints cp as aligned columns of the character, code point, name
+// and category. tabwriter counts runes, not columns, so the character column
+// is padded by its display width and the others are aligned by tabwriter.
+func printTable(opts *options, cp []ucd.CodePoint) error {
+	chars := []string{"char"}
+	widths := []int{len("char")}
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, "code point\t| name\t| category")
+	maxWidth := widths[0]
+	for _, c := range cp {
+		s := opts.glyph(c)
+		var w int
+		for _, r := range s {
+			w += displayWidth(r)
+		}
+		if w > maxWidth {
+			maxWidth = w
+		}
+		chars = append(chars, s)
+		widths = append(widths, w)
+		fmt.Fprintf(tw, "%s\t| %s\t| %s\n", codePoints(c), c.Desc, c.Category.Name)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		pad := strings.Repeat(" ", maxWidth-widths[i])
+		if _, err := fmt.Fprintf(opts.stdout, "%s%s | %s\n", chars[i], pad, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
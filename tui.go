@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rafael-luigi-bekkema/unifind/ucd"
+	"golang.org/x/term"
+)
+
+// browser is the state of the interactive mode.
+type browser struct {
+	all      []ucd.CodePoint
+	opts     ucd.Options
+	query    []rune
+	matches  []ucd.CodePoint
+	err      error
+	selected int
+	offset   int
+}
+
+func (b *browser) filter() {
+	cp, err := ucd.Filter(b.all, string(b.query), b.opts)
+	b.err = err
+	if err != nil {
+		return
+	}
+	b.matches = cp
+	b.selected, b.offset = 0, 0
+}
+
+func (b *browser) move(n int) {
+	b.selected += n
+	if b.selected >= len(b.matches) {
+		b.selected = len(b.matches) - 1
+	}
+	if b.selected < 0 {
+		b.selected = 0
+	}
+}
+
+// draw renders the query line and as many matches as fit below it.
+func (b *browser) draw(w *bufio.Writer, width, height int) {
+	rows := height - 2
+	if b.selected < b.offset {
+		b.offset = b.selected
+	}
+	if b.selected >= b.offset+rows {
+		b.offset = b.selected - rows + 1
+	}
+	w.WriteString("\x1b[H\x1b[2J")
+	status := fmt.Sprintf("%d matches", len(b.matches))
+	if b.err != nil {
+		status = b.err.Error()
+	}
+	fmt.Fprintf(w, "%s\r\n", truncate(status, width))
+	for i := b.offset; i < len(b.matches) && i < b.offset+rows; i++ {
+		c := b.matches[i]
+		line := truncate(fmt.Sprintf("%c  %-8U %s", glyph(c.Chr), c.Chr, c.Desc), width)
+		if i == b.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		fmt.Fprintf(w, "%s\r\n", line)
+	}
+	fmt.Fprintf(w, "\x1b[%d;1H> %s", height, truncate(string(b.query), width-2))
+	w.Flush()
+}
+
+// glyph returns r, or a space if printing r could upset the terminal.
+func glyph(r rune) rune {
+	if !unicode.IsGraphic(r) {
+		return ' '
+	}
+	return r
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// browse runs the interactive mode, filtering the NamesList as the query is
+// typed. It reads the keys from opts.input, which must be a terminal, and
+// draws on opts.stderr. Enter prints the selected character and copies it to
+// the clipboard.
+func browse(opts *options, searchOpts ucd.Options) error {
+	in, ok := opts.input.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) {
+		return fmt.Errorf("interactive mode needs a terminal")
+	}
+	all, err := opts.cache.SearchWith("", searchOpts)
+	if err != nil {
+		return err
+	}
+	fd := int(in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("could not set up terminal: %w", err)
+	}
+	// The size is that of the terminal of stderr, or 80x24 if it is not
+	// one.
+	sizeFd := -1
+	if out, ok := opts.stderr.(*os.File); ok {
+		sizeFd = int(out.Fd())
+	}
+	w := bufio.NewWriter(opts.stderr)
+	w.WriteString("\x1b[?1049h")
+	b := &browser{all: all, opts: searchOpts}
+	picked, err := b.run(in, w, sizeFd)
+	w.WriteString("\x1b[?1049l")
+	w.Flush()
+	term.Restore(fd, state)
+	if err != nil || picked == nil {
+		return err
+	}
+	fmt.Fprintf(opts.stdout, "%c\n", picked.Chr)
+	if err := copyToClipboard(string(picked.Chr)); err != nil {
+		return fmt.Errorf("could not copy to clipboard: %w", err)
+	}
+	opts.errorf("copied %c %U to the clipboard\n", picked.Chr, picked.Chr)
+	return nil
+}
+
+// run handles key presses until a match is picked with Enter, or nil is
+// returned because the user quit. The keys are read from in and the screen
+// is drawn on w, sized like the terminal sizeFd.
+func (b *browser) run(in io.Reader, w *bufio.Writer, sizeFd int) (*ucd.CodePoint, error) {
+	b.filter()
+	buf := make([]byte, 64)
+	for {
+		width, height, err := term.GetSize(sizeFd)
+		if err != nil || height < 3 {
+			width, height = 80, 24
+		}
+		b.draw(w, width, height)
+		n, err := in.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		key := string(buf[:n])
+		switch key {
+		case "\x03", "\x04", "\x1b":
+			return nil, nil
+		case "\r", "\n":
+			if len(b.matches) > 0 {
+				return &b.matches[b.selected], nil
+			}
+		case "\x1b[A", "\x10":
+			b.move(-1)
+		case "\x1b[B", "\x0e":
+			b.move(1)
+		case "\x1b[5~":
+			b.move(-(height - 2))
+		case "\x1b[6~":
+			b.move(height - 2)
+		case "\x7f", "\x08":
+			if len(b.query) > 0 {
+				b.query = b.query[:len(b.query)-1]
+				b.filter()
+			}
+		case "\x15":
+			b.query = b.query[:0]
+			b.filter()
+		default:
+			if buf[0] == '\x1b' {
+				continue
+			}
+			for _, r := range key {
+				if unicode.IsPrint(r) {
+					b.query = append(b.query, r)
+				}
+			}
+			b.filter()
+		}
+	}
+}
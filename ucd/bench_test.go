@@ -0,0 +1,88 @@
+package ucd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// benchNamesList returns a NamesList of about the size of the full one, with
+// blocks of 256 letters of which one is an arrow.
+func benchNamesList(b *testing.B) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("@@@\tThe Unicode Standard\n")
+	for r := 0x10000; buf.Len() < 1700<<10; r += 256 {
+		fmt.Fprintf(&buf, "@@\t%04X\tBlock %04X\t%04X\n@\t\tLetters\n", r, r, r+255)
+		for i := 0; i < 255; i++ {
+			fmt.Fprintf(&buf, "%04X\tSAMPLE LETTER %d\n\t= letter %d\n", r+i, i, i)
+		}
+		fmt.Fprintf(&buf, "%04X\tRIGHTWARDS ARROW %04X\n\tx 2192\n", r+255, r)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkParseNamesList(b *testing.B) {
+	data := benchNamesList(b)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var cp []CodePoint
+		err := parseNamesList(bytes.NewReader(data), func(c CodePoint) {
+			cp = append(cp, c)
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseNamesListParallel(b *testing.B) {
+	data := benchNamesList(b)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseNamesListParallel(context.Background(), data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSearchReader parses the NamesList for every search, as on a cold
+// cache.
+func BenchmarkSearchReader(b *testing.B) {
+	data := benchNamesList(b)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := SearchReader(bytes.NewReader(data), "rightwards arrow", Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkSearch searches the entries of the NamesList, which is parsed
+// once before the timer starts.
+func benchmarkSearch(b *testing.B, search string) {
+	var all []CodePoint
+	parseNamesList(bytes.NewReader(benchNamesList(b)), func(c CodePoint) {
+		all = append(all, c)
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := searchEntries(context.Background(), func(context.Context) ([]CodePoint, error) {
+			return all, nil
+		}, search, Options{}, func(CodePoint) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	benchmarkSearch(b, "rightwards arrow")
+}
+
+func BenchmarkSearchManyHits(b *testing.B) {
+	benchmarkSearch(b, "letter")
+}
@@ -0,0 +1,101 @@
+package ucd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Confusables returns the characters that look like r according to
+// confusables.txt of the Unicode security mechanisms, in code point order.
+func Confusables(r rune) ([]rune, error) {
+	return DefaultCache.Confusables(r)
+}
+
+// Confusables is like the package level Confusables, but uses the files of
+// c.
+func (c *Cache) Confusables(r rune) ([]rune, error) {
+	var skeletons map[rune]string
+	err := c.parseFile(context.Background(), "", confusablesFile, func(r io.Reader) (perrs ParseErrors, err error) {
+		skeletons, perrs, err = parseConfusables(r)
+		return perrs, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Characters are confusable when they map to the same prototype, which
+	// maps to itself.
+	prototype, ok := skeletons[r]
+	if !ok {
+		prototype = string(r)
+	}
+	var near []rune
+	for s, p := range skeletons {
+		if p == prototype && s != r {
+			near = append(near, s)
+		}
+	}
+	if p := []rune(prototype); len(p) == 1 && p[0] != r {
+		near = append(near, p[0])
+	}
+	sort.Slice(near, func(i, j int) bool {
+		return near[i] < near[j]
+	})
+	return near, nil
+}
+
+// parseConfusables returns the prototypes of the characters of the
+// confusables.txt read from r, whose lines look like:
+//
+//	0410 ;	0041 ;	MA	# ( А → A ) CYRILLIC CAPITAL LETTER A → LATIN CAPITAL LETTER A
+func parseConfusables(r io.Reader) (map[rune]string, ParseErrors, error) {
+	skeletons := make(map[rune]string)
+	var perrs ParseErrors
+	var lineNr int
+	rdr := bufio.NewScanner(r)
+	rdr.Buffer(nil, maxLineSize)
+	for rdr.Scan() {
+		lineNr++
+		line := rdr.Text()
+		if lineNr == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) < 3 {
+			perrs.add(confusablesFile, lineNr, fmt.Errorf("invalid format, expected 3 fields, got %d", len(fields)))
+			continue
+		}
+		source, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 16, 32)
+		if err != nil {
+			perrs.add(confusablesFile, lineNr, fmt.Errorf("invalid rune %q: %w", fields[0], err))
+			continue
+		}
+		var prototype []rune
+		for _, s := range strings.Fields(fields[1]) {
+			i, err := strconv.ParseInt(s, 16, 32)
+			if err != nil {
+				perrs.add(confusablesFile, lineNr, fmt.Errorf("invalid rune %q: %w", s, err))
+				prototype = nil
+				break
+			}
+			prototype = append(prototype, rune(i))
+		}
+		if prototype != nil {
+			skeletons[rune(source)] = string(prototype)
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %w", confusablesFile, err)
+	}
+	return skeletons, perrs, nil
+}
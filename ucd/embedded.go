@@ -0,0 +1,27 @@
+package ucd
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"time"
+)
+
+// embeddedNamesList is a NamesList of the blocks of ASCII, Latin-1 and the
+// common symbols, so the most used characters can be found without a
+// download.
+//
+//go:embed embedded/NamesList.txt
+var embeddedNamesList []byte
+
+// parseEmbedded parses embeddedNamesList and adds it to the Stats of c like
+// a NamesList that was read from a file.
+func (c *Cache) parseEmbedded() (*namesList, error) {
+	start := time.Now()
+	nl, err := parseNamesListParallel(context.Background(), embeddedNamesList)
+	c.addStats(func(s *Stats) {
+		s.Parse += time.Since(start)
+		s.Lines += bytes.Count(embeddedNamesList, []byte("\n"))
+	})
+	return nl, err
+}
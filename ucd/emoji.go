@@ -0,0 +1,111 @@
+package ucd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SearchEmojiFunc is like SearchFunc, but searches the fully-qualified
+// emoji of emoji-test.txt instead of the NamesList. Their Category is the
+// emoji group, like "Smileys & Emotion", and their Subcategory the
+// subgroup.
+func SearchEmojiFunc(search string, opts Options, fn func(CodePoint) error) error {
+	return SearchEmojiContext(context.Background(), search, opts, fn)
+}
+
+// SearchEmojiContext is like SearchEmojiFunc, but stops when ctx is done and
+// returns its error.
+func SearchEmojiContext(ctx context.Context, search string, opts Options, fn func(CodePoint) error) error {
+	return DefaultCache.SearchEmojiContext(ctx, search, opts, fn)
+}
+
+// SearchEmojiContext is like the package level SearchEmojiContext, but uses
+// the files of c.
+func (c *Cache) SearchEmojiContext(ctx context.Context, search string, opts Options, fn func(CodePoint) error) error {
+	return searchEntries(ctx, c.loadEmoji, search, opts, fn)
+}
+
+func (c *Cache) loadEmoji(ctx context.Context) (cp []CodePoint, err error) {
+	err = c.parseFile(ctx, "", emojiTestFile, func(r io.Reader) (perrs ParseErrors, err error) {
+		cp, perrs, err = parseEmojiTest(r)
+		return perrs, err
+	})
+	return cp, err
+}
+
+// parseEmojiTest returns the fully-qualified emoji of the emoji-test.txt
+// read from r, whose lines look like:
+//
+//	1F636 200D 1F32B FE0F ; fully-qualified # 😶‍🌫️ E13.1 face in clouds
+func parseEmojiTest(r io.Reader) ([]CodePoint, ParseErrors, error) {
+	var cp []CodePoint
+	var perrs ParseErrors
+	var group, subgroup string
+	var lineNr int
+	rdr := bufio.NewScanner(r)
+	rdr.Buffer(nil, maxLineSize)
+	for rdr.Scan() {
+		lineNr++
+		line := rdr.Text()
+		if strings.HasPrefix(line, "# group: ") {
+			group = line[len("# group: "):]
+			continue
+		}
+		if strings.HasPrefix(line, "# subgroup: ") {
+			subgroup = line[len("# subgroup: "):]
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		semi, hash := strings.Index(line, ";"), strings.Index(line, "#")
+		if semi < 0 || hash < semi {
+			perrs.add(emojiTestFile, lineNr, fmt.Errorf("invalid format, expected code points; status # comment"))
+			continue
+		}
+		if strings.TrimSpace(line[semi+1:hash]) != "fully-qualified" {
+			continue
+		}
+		var seq []rune
+		var err error
+		for _, s := range strings.Fields(line[:semi]) {
+			var i int64
+			if i, err = strconv.ParseInt(s, 16, 32); err != nil {
+				break
+			}
+			seq = append(seq, rune(i))
+		}
+		if err != nil || len(seq) == 0 {
+			perrs.add(emojiTestFile, lineNr, fmt.Errorf("invalid code points %q", strings.TrimSpace(line[:semi])))
+			continue
+		}
+		// The comment is the emoji, the version it was added in and its name.
+		comment := strings.Fields(line[hash+1:])
+		if len(comment) < 3 {
+			perrs.add(emojiTestFile, lineNr, fmt.Errorf("missing emoji name"))
+			continue
+		}
+		name := strings.Join(comment[2:], " ")
+		c := CodePoint{
+			Chr:         seq[0],
+			Name:        name,
+			Desc:        name,
+			FullDesc:    []string{name},
+			Category:    Category{Name: group},
+			Subcategory: subgroup,
+			IsEmoji:     true,
+		}
+		if len(seq) > 1 {
+			c.Sequence = string(seq)
+		}
+		cp = append(cp, c)
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %w", emojiTestFile, err)
+	}
+	return cp, perrs, nil
+}
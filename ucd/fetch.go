@@ -0,0 +1,442 @@
+package ucd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	ucdURL      = "https://www.unicode.org/Public/UCD/%s/ucd/%s"
+	emojiURL    = "https://www.unicode.org/Public/emoji/%s/%s"
+	securityURL = "https://www.unicode.org/Public/security/%s/%s"
+)
+const appName = "unifind"
+
+const (
+	indexFile       = "Index.txt"
+	namesListFile   = "NamesList.txt"
+	unicodeDataFile = "UnicodeData.txt"
+	emojiTestFile   = "emoji-test.txt"
+	confusablesFile = "confusables.txt"
+	scriptsFile     = "Scripts.txt"
+	derivedAgeFile  = "DerivedAge.txt"
+)
+
+// LatestVersion is the Version of the most recent Unicode release.
+const LatestVersion = "latest"
+
+var versionRe = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+// Property is a set of the properties of UCD files other than the NamesList
+// that can be added to its entries.
+type Property uint
+
+const (
+	// PropUnicodeData is the general category, bidi class and numeric
+	// value of UnicodeData.txt.
+	PropUnicodeData Property = 1 << iota
+	// PropScript is the script of Scripts.txt.
+	PropScript
+	// PropAge is the version of DerivedAge.txt.
+	PropAge
+
+	AllProps = PropUnicodeData | PropScript | PropAge
+)
+
+// DefaultMaxAge is the MaxAge of DefaultCache.
+const DefaultMaxAge = 90 * 24 * time.Hour
+
+// DefaultTimeout is the Timeout of DefaultCache.
+const DefaultTimeout = 30 * time.Second
+
+// downloadAttempts is how many times a download that failed for a reason
+// that might go away is tried.
+const downloadAttempts = 3
+
+// Cache downloads UCD files and keeps them in the user cache directory.
+type Cache struct {
+	// Offline disables downloading files that are not cached yet.
+	Offline bool
+	// Refresh forces cached files to be downloaded again.
+	Refresh bool
+	// MaxAge is how long a cached file is used before it is downloaded
+	// again. Zero means cached files never go stale.
+	MaxAge time.Duration
+	// Timeout limits how long a download may take, including reading the
+	// file. Zero means no limit.
+	Timeout time.Duration
+	// Proxy returns the proxy to download a file through, like the Proxy
+	// of http.Transport. Nil means http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+	// Version is the Unicode version to download, e.g. 15.0.0. Empty means
+	// LatestVersion.
+	Version string
+	// NamesList and Index are paths of local files to read instead of the
+	// downloaded NamesList.txt and Index.txt.
+	NamesList string
+	Index     string
+	// UnicodeData is the path of a local file to read instead of the
+	// downloaded UnicodeData.txt.
+	UnicodeData string
+	// Properties are the properties of other UCD files that are added to
+	// the entries of the NamesList, like PropScript, or AllProps. Each of
+	// them makes searches read its file as well.
+	Properties Property
+	// Strict makes searches fail on lines of the UCD files that can not be
+	// parsed, instead of skipping them with a warning.
+	Strict bool
+	// Embedded makes searches use only the subset of the NamesList that is
+	// embedded in the package, which is also used when the NamesList can
+	// not be downloaded because unicode.org can not be reached.
+	Embedded bool
+	// BaseDir is the directory the cache directory is made in, instead of
+	// the user cache directory.
+	BaseDir string
+	// Progress is where downloads are reported while they happen, or nil to
+	// download silently.
+	Progress io.Writer
+	// Warnings is where problems that do not stop a search are reported,
+	// like skipped lines or a stale cache file that is used, or nil to
+	// ignore them.
+	Warnings io.Writer
+	// Exclude lists the blocks of the NamesList whose entries are left
+	// out, compared ignoring case.
+	Exclude []string
+
+	// The parsed files are kept, so searching more than once in the same
+	// process reads them once.
+	mu          sync.Mutex
+	namesList   *namesList
+	unicodeData *unicodeData
+	scripts     valueRanges
+	ages        valueRanges
+
+	statsMu sync.Mutex
+	stats   Stats
+}
+
+// DefaultExclude is the Exclude of DefaultCache. These blocks have hundreds
+// of entries with names that match almost any search and bury the results:
+// Sutton SignWriting has names like "signwriting hand-fist index" that match
+// searches for hands, fingers and faces, and the Runic and Coptic letters are
+// named after the Latin and Greek letters they transliterate, so they match
+// every search for a letter.
+var DefaultExclude = []string{"Sutton SignWriting", "Runic", "Coptic"}
+
+// DefaultCache is the Cache used by the package level functions.
+var DefaultCache = &Cache{MaxAge: DefaultMaxAge, Timeout: DefaultTimeout, Exclude: DefaultExclude, Warnings: os.Stderr}
+
+// Open returns the cached copy of the UCD file name, e.g. NamesList.txt,
+// downloading it first if it is missing or stale.
+func (c *Cache) Open(name string) (io.ReadCloser, error) {
+	return c.OpenContext(context.Background(), name)
+}
+
+// OpenContext is like Open, but gives up downloading the file when ctx is
+// done.
+func (c *Cache) OpenContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	return c.open(ctx, name)
+}
+
+func (c *Cache) open(ctx context.Context, name string) (*os.File, error) {
+	version := c.Version
+	if version == "" {
+		version = LatestVersion
+	}
+	if version != LatestVersion && !versionRe.MatchString(version) {
+		return nil, fmt.Errorf("invalid Unicode version %q, expected e.g. 15.0.0", version)
+	}
+	cacheDir, err := c.Dir()
+	if err != nil {
+		return nil, err
+	}
+	cacheDir = filepath.Join(cacheDir, version)
+	cachePath := filepath.Join(cacheDir, name)
+	f, err := os.Open(cachePath)
+	if err == nil {
+		if c.Offline || (!c.Refresh && !c.isStale(f)) {
+			return f, nil
+		}
+		f.Close()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not open file %q: %w", cachePath, err)
+	} else if c.Offline {
+		return nil, fmt.Errorf("offline mode: %s is not cached, expected it at %q", name, cachePath)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not make cache path %s: %w", cachePath, err)
+	}
+	url := fileURL(version, name)
+	start := time.Now()
+	err = c.download(ctx, url, cachePath)
+	c.addStats(func(s *Stats) { s.Download += time.Since(start) })
+	if err != nil {
+		var serr *statusError
+		if errors.As(err, &serr) && serr.code == http.StatusNotFound && version != LatestVersion {
+			return nil, fmt.Errorf("Unicode version %s was not found on unicode.org: %w", version, err)
+		}
+		if f == nil || c.Refresh || ctx.Err() != nil {
+			return nil, err
+		}
+		c.warnf("using stale cache file %q: %s\n", cachePath, err)
+	}
+	f, err = os.Open(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file %q: %w", cachePath, err)
+	}
+	return f, nil
+}
+
+// fileURL returns where the file name of Unicode version is downloaded
+// from. The emoji and security files are not part of the UCD, and the emoji
+// files only have a major and minor version.
+func fileURL(version, name string) string {
+	switch name {
+	case confusablesFile:
+		return fmt.Sprintf(securityURL, version, name)
+	case emojiTestFile:
+		if version != LatestVersion {
+			version = version[:strings.LastIndex(version, ".")]
+		}
+		return fmt.Sprintf(emojiURL, version, name)
+	}
+	return fmt.Sprintf(ucdURL, version, name)
+}
+
+// Dir returns the directory the files are cached in, with a directory for
+// each Unicode version.
+func (c *Cache) Dir() (string, error) {
+	dir := c.BaseDir
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", fmt.Errorf("could not find user cache dir: %w", err)
+		}
+	}
+	return filepath.Join(dir, appName, "ucd"), nil
+}
+
+// Files returns the paths of the files in the cache directory.
+func (c *Cache) Files() ([]string, error) {
+	dir, err := c.Dir()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list cache dir %q: %w", dir, err)
+	}
+	return files, nil
+}
+
+// Clear removes the cache directory and returns the paths of the files that
+// were in it.
+func (c *Cache) Clear() ([]string, error) {
+	removed, err := c.Files()
+	if err != nil || removed == nil {
+		return nil, err
+	}
+	dir, err := c.Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("could not remove cache dir %q: %w", dir, err)
+	}
+	return removed, nil
+}
+
+func (c *Cache) openLocal(ctx context.Context, local, name string) (*os.File, error) {
+	if local == "" {
+		return c.open(ctx, name)
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", name, err)
+	}
+	return f, nil
+}
+
+func (c *Cache) isStale(f *os.File) bool {
+	if c.MaxAge == 0 {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && time.Since(fi.ModTime()) > c.MaxAge
+}
+
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("could not fetch %q: server responded with %s", e.url, e.status)
+}
+
+// download fetches url to cachePath, trying again with increasing delays
+// when it times out or the server has a temporary problem, until ctx is
+// done.
+func (c *Cache) download(ctx context.Context, url, cachePath string) error {
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(time.Duration(attempt-1) * time.Second):
+			case <-ctx.Done():
+				return fmt.Errorf("could not fetch %q: %w", url, ctx.Err())
+			}
+		}
+		err = c.fetch(ctx, url, cachePath)
+		if err == nil || !temporary(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return fmt.Errorf("network timeout, gave up after %d attempts: %w", downloadAttempts, err)
+	}
+	return fmt.Errorf("server error, gave up after %d attempts: %w", downloadAttempts, err)
+}
+
+// temporary reports whether the download that failed with err might
+// succeed when tried again.
+func temporary(err error) bool {
+	var serr *statusError
+	if errors.As(err, &serr) {
+		return serr.code >= 500 || serr.code == http.StatusTooManyRequests
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout() ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// unavailable reports whether the download that failed with err could not
+// reach unicode.org or got no answer from it, rather than that the file
+// does not exist or could not be saved.
+func unavailable(err error) bool {
+	var serr *statusError
+	if errors.As(err, &serr) {
+		return serr.code >= 500 || serr.code == http.StatusTooManyRequests
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// client returns the HTTP client downloads use. It goes through the proxy
+// of c.Proxy, or of HTTP_PROXY, HTTPS_PROXY and NO_PROXY, even if another
+// package changed http.DefaultTransport.
+func (c *Cache) client() *http.Client {
+	proxy := c.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	t := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{Transport: t, Timeout: c.Timeout}
+}
+
+// fetch downloads url into a temporary file next to cachePath and only
+// moves it into place once it is complete, so an existing cache file is
+// never replaced by a partial download.
+func (c *Cache) fetch(ctx context.Context, url, cachePath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("could not fetch %q: %w", url, err)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("could not fetch %q: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{url, resp.Proto + " " + resp.Status, resp.StatusCode}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file for %q: %w", cachePath, err)
+	}
+	defer os.Remove(tmp.Name())
+	var body io.Reader = resp.Body
+	if c.Progress != nil {
+		p := &progress{w: c.Progress, name: filepath.Base(cachePath), size: resp.ContentLength}
+		p.print()
+		body = io.TeeReader(body, p)
+		defer p.done()
+	}
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not download %q to %q: %w", url, cachePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not download %q to %q: %w", url, cachePath, err)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return fmt.Errorf("could not move download to %q: %w", cachePath, err)
+	}
+	return nil
+}
+
+// progress reports how many of the size bytes of a download have been
+// written to it. The size is -1 if the server did not send it.
+type progress struct {
+	w    io.Writer
+	name string
+	size int64
+	n    int64
+	last time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.n += int64(len(b))
+	if time.Since(p.last) >= 100*time.Millisecond {
+		p.print()
+	}
+	return len(b), nil
+}
+
+func (p *progress) print() {
+	p.last = time.Now()
+	if p.size < 0 {
+		fmt.Fprintf(p.w, "\rDownloading %s... %s", p.name, megabytes(p.n))
+		return
+	}
+	fmt.Fprintf(p.w, "\rDownloading %s... %s of %s", p.name, megabytes(p.n), megabytes(p.size))
+}
+
+func (p *progress) done() {
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+func megabytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
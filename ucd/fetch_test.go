@@ -0,0 +1,54 @@
+package ucd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFetchThroughProxy(t *testing.T) {
+	var got string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.String()
+		fmt.Fprintln(w, "0041\tLATIN CAPITAL LETTER A")
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Cache{Timeout: DefaultTimeout, Proxy: http.ProxyURL(proxyURL)}
+	const want = "http://www.unicode.org/Public/UCD/latest/ucd/NamesList.txt"
+	if err := c.fetch(context.Background(), want, filepath.Join(t.TempDir(), namesListFile)); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("proxy got request for %q, want %q", got, want)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Get", URL: "https://www.unicode.org/", Err: errors.New("dial tcp: lookup www.unicode.org: no such host")}, true},
+		{fmt.Errorf("could not download: %w", syscall.ECONNRESET), true},
+		{&statusError{"https://www.unicode.org/", "HTTP/1.1 503 Service Unavailable", 503}, true},
+		{&statusError{"https://www.unicode.org/", "HTTP/1.1 404 Not Found", 404}, false},
+		{fmt.Errorf("could not create temporary file: %w", fs.ErrPermission), false},
+		{errors.New(`invalid Unicode version "abc", expected e.g. 15.0.0`), false},
+	}
+	for _, tt := range tests {
+		if got := unavailable(tt.err); got != tt.want {
+			t.Errorf("unavailable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
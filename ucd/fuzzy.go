@@ -0,0 +1,90 @@
+package ucd
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// fuzzyScore returns the summed edit distance between each of terms and the
+// closest word in target, and whether every term was close enough to match.
+// A term contained in target has distance zero, so entries that would also
+// match a Substring search score best.
+func fuzzyScore(target []string, terms []string) (int, bool) {
+	var score int
+	for _, term := range terms {
+		best := -1
+		for _, t := range target {
+			if strings.Contains(t, term) {
+				best = 0
+				break
+			}
+			for _, word := range strings.Fields(t) {
+				d := levenshtein(term, strings.TrimFunc(word, isPunct))
+				if best == -1 || d < best {
+					best = d
+				}
+			}
+		}
+		if best == -1 || best > maxDistance(term) {
+			return 0, false
+		}
+		score += best
+	}
+	return score, true
+}
+
+// maxDistance is the largest edit distance at which term still matches a word.
+func maxDistance(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// ranked sorts code points by ascending score.
+type ranked struct {
+	cp     []CodePoint
+	scores []int
+}
+
+func (r ranked) Len() int           { return len(r.cp) }
+func (r ranked) Less(i, j int) bool { return r.scores[i] < r.scores[j] }
+func (r ranked) Swap(i, j int) {
+	r.cp[i], r.cp[j] = r.cp[j], r.cp[i]
+	r.scores[i], r.scores[j] = r.scores[j], r.scores[i]
+}
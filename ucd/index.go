@@ -0,0 +1,381 @@
+package ucd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// indexVersion must be incremented whenever the index format or the parsed
+// NamesList changes shape, so indexes written by older versions are rebuilt.
+const indexVersion = 7
+
+const indexMagic = "unifind index\n"
+
+var errStaleIndex = errors.New("index is stale")
+
+// namesList is a parsed NamesList.
+type namesList struct {
+	entries []CodePoint
+	// blocks are all blocks with a header, also the ones without entries
+	// like the surrogates.
+	blocks []Category
+}
+
+// loadNamesList returns the entries of the NamesList outside the blocks of
+// c.Exclude, with the properties of UnicodeData.txt if props is set, the
+// scripts of Scripts.txt if scripts is set and the ages of DerivedAge.txt if
+// ages is set. The files are read the first time, after that the returned
+// entries are a copy of the ones kept in c.
+func (c *Cache) loadNamesList(ctx context.Context, props, scripts, ages bool) ([]CodePoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nl, err := c.parsedNamesList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cp := make([]CodePoint, 0, len(nl.entries))
+	for _, e := range nl.entries {
+		if !c.excluded(e.Category.Name) {
+			cp = append(cp, e)
+		}
+	}
+	if props {
+		if c.unicodeData == nil {
+			d, err := c.loadUnicodeData(ctx)
+			if err != nil {
+				return nil, err
+			}
+			c.unicodeData = d
+		}
+		c.unicodeData.addProperties(cp)
+	}
+	if scripts {
+		if err := c.loadScripts(ctx); err != nil {
+			return nil, err
+		}
+		for i := range cp {
+			cp[i].Script = c.scripts.lookup(cp[i].Chr)
+		}
+	}
+	if ages {
+		if c.ages == nil {
+			v, err := c.loadRanges(ctx, derivedAgeFile)
+			if err != nil {
+				return nil, err
+			}
+			c.ages = v
+		}
+		for i := range cp {
+			cp[i].Age = c.ages.lookup(cp[i].Chr)
+		}
+	}
+	return cp, nil
+}
+
+// parsedNamesList returns the parsed NamesList, which is read the first
+// time, c.mu must be held.
+func (c *Cache) parsedNamesList(ctx context.Context) (*namesList, error) {
+	if c.namesList != nil {
+		return c.namesList, nil
+	}
+	nl, keep, err := c.readNamesList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if keep {
+		c.namesList = nl
+	}
+	return nl, nil
+}
+
+func (c *Cache) excluded(block string) bool {
+	for _, name := range c.Exclude {
+		if strings.EqualFold(name, block) {
+			return true
+		}
+	}
+	return false
+}
+
+// readNamesList returns the parsed NamesList. The parsed entries of
+// a cached NamesList.txt are kept in a binary index, which is used instead
+// of parsing it again until the NamesList.txt changes. If the NamesList can
+// not be downloaded because unicode.org can not be reached, the embedded
+// subset is returned with keep false, so the next search tries again
+// instead of keeping it.
+func (c *Cache) readNamesList(ctx context.Context) (nl *namesList, keep bool, err error) {
+	if c.Embedded {
+		nl, err = c.parseEmbedded()
+		return nl, true, err
+	}
+	f, err := c.openLocal(ctx, c.NamesList, namesListFile)
+	if ctx.Err() != nil {
+		if f != nil {
+			f.Close()
+		}
+		return nil, false, ctx.Err()
+	}
+	if err != nil && c.NamesList == "" && unavailable(err) {
+		c.warnf("%s, using the embedded subset of common characters\n", err)
+		nl, err = c.parseEmbedded()
+		return nl, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	var source sourceStamp
+	var indexPath string
+	if c.NamesList == "" {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, false, fmt.Errorf("could not stat %q: %w", f.Name(), err)
+		}
+		source = sourceStamp{fi.Size(), fi.ModTime().UnixNano()}
+		indexPath = strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())) + ".idx"
+		start := time.Now()
+		if nl, perrs, err := readIndex(indexPath, source); err == nil {
+			c.addStats(func(s *Stats) {
+				s.Parse += time.Since(start)
+				s.Index = true
+			})
+			if err := c.skipped(perrs); err != nil {
+				return nil, false, err
+			}
+			return nl, true, nil
+		}
+	}
+	start := time.Now()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read %s: %w", namesListFile, err)
+	}
+	nl, err = parseNamesListParallel(ctx, data)
+	c.addStats(func(s *Stats) {
+		s.Parse += time.Since(start)
+		s.Lines += bytes.Count(data, []byte("\n"))
+	})
+	var perrs ParseErrors
+	if err != nil && !errors.As(err, &perrs) {
+		return nil, false, err
+	}
+	// The skipped lines are kept in the index, so they are still reported
+	// when it is used.
+	if indexPath != "" {
+		if err := writeIndex(indexPath, source, nl, perrs); err != nil {
+			c.warnf("could not write index: %s\n", err)
+		}
+	}
+	if err := c.skipped(perrs); err != nil {
+		return nil, false, err
+	}
+	return nl, true, nil
+}
+
+// sourceStamp identifies the version of the NamesList.txt an index was
+// built from.
+type sourceStamp struct {
+	size    int64
+	modTime int64
+}
+
+// The index starts with indexMagic, indexVersion and the sourceStamp,
+// followed by the number of blocks, the blocks and then the other distinct
+// categories of the entries, the distinct subcategories, the entries, which
+// refer to those by number, and the line number and error of the skipped
+// lines. Numbers are uvarints and strings are their length followed by
+// their bytes.
+
+func writeIndex(path string, source sourceStamp, nl *namesList, perrs ParseErrors) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file for %q: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	w := indexWriter{w: bufio.NewWriter(tmp)}
+	w.w.WriteString(indexMagic)
+	w.uint(indexVersion)
+	w.uint(uint64(source.size))
+	w.uint(uint64(source.modTime))
+
+	cats := make(map[Category]uint64)
+	subcats := make(map[string]uint64)
+	var catList []Category
+	var subcatList []string
+	for _, b := range nl.blocks {
+		if _, ok := cats[b]; !ok {
+			cats[b] = uint64(len(catList))
+			catList = append(catList, b)
+		}
+	}
+	blocks := len(catList)
+	for _, c := range nl.entries {
+		if _, ok := cats[c.Category]; !ok {
+			cats[c.Category] = uint64(len(catList))
+			catList = append(catList, c.Category)
+		}
+		if _, ok := subcats[c.Subcategory]; !ok {
+			subcats[c.Subcategory] = uint64(len(subcatList))
+			subcatList = append(subcatList, c.Subcategory)
+		}
+	}
+	w.uint(uint64(blocks))
+	w.uint(uint64(len(catList)))
+	for _, cat := range catList {
+		w.string(cat.Name)
+		w.string(cat.Start)
+		w.string(cat.End)
+		w.string(cat.Description)
+	}
+	w.uint(uint64(len(subcatList)))
+	for _, s := range subcatList {
+		w.string(s)
+	}
+	w.uint(uint64(len(nl.entries)))
+	for _, c := range nl.entries {
+		w.uint(uint64(c.Chr))
+		w.uint(cats[c.Category])
+		w.uint(subcats[c.Subcategory])
+		// The name is kept as it is, newCodePoint collapses its spaces
+		// again for Desc.
+		w.uint(uint64(len(c.FullDesc)))
+		w.string(c.Name)
+		for _, d := range c.FullDesc[1:] {
+			w.string(d)
+		}
+	}
+	w.uint(uint64(len(perrs)))
+	for _, e := range perrs {
+		w.uint(uint64(e.Line))
+		w.string(e.Err.Error())
+	}
+	if err := w.w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %q: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %q: %w", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type indexWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *indexWriter) uint(v uint64) {
+	n := binary.PutUvarint(w.buf[:], v)
+	w.w.Write(w.buf[:n])
+}
+
+func (w *indexWriter) string(s string) {
+	w.uint(uint64(len(s)))
+	w.w.WriteString(s)
+}
+
+// readIndex returns the NamesList and skipped lines of the index at path, or
+// errStaleIndex if it was not built from source by this version.
+func readIndex(path string, source sourceStamp) (*namesList, ParseErrors, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Converting once lets every string of the index share the same memory.
+	r := indexReader{data: string(b)}
+	if !strings.HasPrefix(r.data, indexMagic) {
+		return nil, nil, errStaleIndex
+	}
+	r.off = len(indexMagic)
+	if r.uint() != indexVersion || int64(r.uint()) != source.size || int64(r.uint()) != source.modTime {
+		return nil, nil, errStaleIndex
+	}
+	blocks := r.count()
+	cats := make([]Category, r.count())
+	if blocks > len(cats) {
+		return nil, nil, errStaleIndex
+	}
+	for i := range cats {
+		cats[i] = Category{Name: r.string(), Start: r.string(), End: r.string(), Description: r.string()}
+	}
+	subcats := make([]string, r.count())
+	for i := range subcats {
+		subcats[i] = r.string()
+	}
+	nl := &namesList{blocks: cats[:blocks]}
+	if n := r.count(); r.err == nil {
+		nl.entries = make([]CodePoint, n)
+	}
+	for i := range nl.entries {
+		chr, cat, subcat := rune(r.uint()), r.uint(), r.uint()
+		fullDesc := make([]string, r.count())
+		for j := range fullDesc {
+			fullDesc[j] = r.string()
+		}
+		if r.err != nil || cat >= uint64(len(cats)) || subcat >= uint64(len(subcats)) || len(fullDesc) == 0 {
+			return nil, nil, errStaleIndex
+		}
+		nl.entries[i] = newCodePoint(chr, fullDesc, cats[cat], subcats[subcat])
+	}
+	var perrs ParseErrors
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		perrs.add(namesListFile, int(r.uint()), errors.New(r.string()))
+	}
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return nl, perrs, nil
+}
+
+type indexReader struct {
+	data string
+	off  int
+	err  error
+}
+
+func (r *indexReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var v uint64
+	var shift uint
+	for ; r.off < len(r.data) && shift < 64; shift += 7 {
+		b := r.data[r.off]
+		r.off++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+	r.err = errStaleIndex
+	return 0
+}
+
+// count reads a length, which can not be more than the bytes left.
+func (r *indexReader) count() int {
+	n := r.uint()
+	if n > uint64(len(r.data)-r.off) {
+		r.err = errStaleIndex
+		return 0
+	}
+	return int(n)
+}
+
+func (r *indexReader) string() string {
+	n := r.count()
+	if r.err != nil {
+		return ""
+	}
+	s := r.data[r.off : r.off+n]
+	r.off += n
+	return s
+}
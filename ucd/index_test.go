@@ -0,0 +1,104 @@
+package ucd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadNamesListRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() context.Context
+		offline bool
+		version string
+	}{
+		{"cancelled", func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}, false, ""},
+		// Only a network problem falls back to the embedded subset.
+		{"offline", context.Background, true, ""},
+		{"invalid version", context.Background, false, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cache{BaseDir: t.TempDir(), Offline: tt.offline, Version: tt.version}
+			_, err := c.loadNamesList(tt.ctx(), false, false, false)
+			if err == nil {
+				t.Fatal("got no error, want the one of reading the NamesList")
+			}
+			if ctx := tt.ctx(); ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+				t.Fatalf("got error %v, want %v", err, ctx.Err())
+			}
+			c.NamesList = testNamesList
+			cp, err := c.loadNamesList(context.Background(), false, false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cp) != 7 {
+				t.Errorf("got %d entries after reading %s, want 7", len(cp), testNamesList)
+			}
+		})
+	}
+}
+
+func TestIndexKeepsSkippedLines(t *testing.T) {
+	data, err := os.ReadFile(testNamesList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl, err := parseNamesListParallel(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perrs := ParseErrors{{namesListFile, 26, errors.New("invalid format, expected 2 fields, got 3")}}
+	path := filepath.Join(t.TempDir(), "NamesList.idx")
+	source := sourceStamp{1, 2}
+	if err := writeIndex(path, source, nl, perrs); err != nil {
+		t.Fatal(err)
+	}
+	got, gotPerrs, err := readIndex(path, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, nl) {
+		t.Errorf("got %+v, want %+v", got, nl)
+	}
+	if len(gotPerrs) != 1 || gotPerrs[0].Error() != perrs[0].Error() {
+		t.Errorf("got skipped lines %v, want %v", gotPerrs, perrs)
+	}
+	if _, _, err := readIndex(path, sourceStamp{1, 3}); err != errStaleIndex {
+		t.Errorf("got error %v for another source, want %v", err, errStaleIndex)
+	}
+}
+
+func TestEmbeddedStats(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *Cache
+	}{
+		{"embedded", &Cache{BaseDir: t.TempDir(), Embedded: true}},
+		// A proxy that fails is a network error, so the NamesList falls
+		// back to the embedded one.
+		{"fallback", &Cache{BaseDir: t.TempDir(), Warnings: io.Discard, Proxy: func(*http.Request) (*url.URL, error) {
+			return nil, errors.New("proxy unreachable")
+		}}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.c.loadNamesList(context.Background(), false, false, false); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		st := tt.c.Stats()
+		if st.Parse == 0 || st.Lines == 0 {
+			t.Errorf("%s: got parse time %s and %d lines, want both nonzero", tt.name, st.Parse, st.Lines)
+		}
+	}
+}
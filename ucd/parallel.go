@@ -0,0 +1,107 @@
+package ucd
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"sync"
+)
+
+// minChunkSize is the size of the parts of the NamesList that are parsed
+// concurrently. The full NamesList of about 1.7MB makes a few dozen of them.
+const minChunkSize = 64 << 10
+
+// namesListChunk is a part of the NamesList that starts with a block header,
+// apart from the first, with its parsed entries.
+type namesListChunk struct {
+	data   []byte
+	lineNr int
+	p      namesListParser
+	cp     []CodePoint
+	err    error
+}
+
+// parseNamesListParallel returns the entries and blocks of the NamesList
+// data, which is split at block headers into parts that are parsed
+// concurrently, and the skipped lines of all parts like parseNamesList. The
+// parts stop being parsed when ctx is done.
+func parseNamesListParallel(ctx context.Context, data []byte) (*namesList, error) {
+	return parseChunks(ctx, splitNamesList(data, minChunkSize))
+}
+
+// parseChunks parses the chunks concurrently and returns their entries and
+// blocks in order, like parseNamesListParallel.
+func parseChunks(ctx context.Context, chunks []namesListChunk) (*namesList, error) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+	next := make(chan *namesListChunk)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ch := range next {
+				ch.parse(ctx)
+			}
+		}()
+	}
+	for i := range chunks {
+		next <- &chunks[i]
+	}
+	close(next)
+	wg.Wait()
+
+	var n int
+	for _, ch := range chunks {
+		n += len(ch.cp)
+	}
+	// A block header resets the block and subcategory, so the parts do not
+	// depend on each other.
+	nl := &namesList{entries: make([]CodePoint, 0, n)}
+	var perrs ParseErrors
+	for _, ch := range chunks {
+		if ch.err != nil {
+			return nil, ch.err
+		}
+		nl.entries = append(nl.entries, ch.cp...)
+		nl.blocks = append(nl.blocks, ch.p.blocks...)
+		perrs = append(perrs, ch.p.perrs...)
+	}
+	if len(perrs) > 0 {
+		return nl, perrs
+	}
+	return nl, nil
+}
+
+func (ch *namesListChunk) parse(ctx context.Context) {
+	ch.p = namesListParser{
+		ctx:    ctx,
+		fn:     func(c CodePoint) { ch.cp = append(ch.cp, c) },
+		lineNr: ch.lineNr,
+	}
+	err := ch.p.parse(bytes.NewReader(ch.data), ch.lineNr == 0)
+	if _, ok := err.(ParseErrors); !ok {
+		ch.err = err
+	}
+}
+
+// splitNamesList splits data into parts of at least size bytes that start at
+// a block header, except the first.
+func splitNamesList(data []byte, size int) []namesListChunk {
+	var chunks []namesListChunk
+	var lineNr int
+	for len(data) > 0 {
+		end := len(data)
+		if len(data) > size {
+			if i := bytes.Index(data[size:], []byte("\n@@\t")); i >= 0 {
+				end = size + i + 1
+			}
+		}
+		chunks = append(chunks, namesListChunk{data: data[:end], lineNr: lineNr})
+		lineNr += bytes.Count(data[:end], []byte("\n"))
+		data = data[end:]
+	}
+	return chunks
+}
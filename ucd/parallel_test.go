@@ -0,0 +1,53 @@
+package ucd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseChunksMatchesSequential(t *testing.T) {
+	fixture, err := os.ReadFile(testNamesList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A line that is skipped in a later block checks that the line numbers
+	// of the parts add up.
+	data := append(fixture, "@@\t1F680\tTransport and Map Symbols\t1F6FF\nXYZ\tNOT A CODE POINT\n1F680\tROCKET\n"...)
+	var want []CodePoint
+	var wantPerrs ParseErrors
+	err = parseNamesList(bytes.NewReader(data), func(c CodePoint) {
+		want = append(want, c)
+	})
+	if !errors.As(err, &wantPerrs) || len(wantPerrs) != 1 {
+		t.Fatalf("got error %v from the sequential parse, want one skipped line", err)
+	}
+
+	chunks := splitNamesList(data, 1)
+	if blocks := bytes.Count(data, []byte("\n@@\t")); len(chunks) != blocks+1 {
+		t.Fatalf("got %d chunks, want one per block and the header, %d", len(chunks), blocks+1)
+	}
+	nl, err := parseChunks(context.Background(), chunks)
+	var gotPerrs ParseErrors
+	if !errors.As(err, &gotPerrs) {
+		t.Fatalf("got error %v, want the skipped line", err)
+	}
+	if len(nl.blocks) != len(chunks)-1 {
+		t.Errorf("got %d blocks, want %d", len(nl.blocks), len(chunks)-1)
+	}
+	got := nl.entries
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d entries that differ from the %d of the sequential parse", len(got), len(want))
+		for i := range want {
+			if i < len(got) && !reflect.DeepEqual(got[i], want[i]) {
+				t.Fatalf("first difference at %U:\ngot  %+v\nwant %+v", want[i].Chr, got[i], want[i])
+			}
+		}
+	}
+	if gotPerrs.Error() != wantPerrs.Error() {
+		t.Errorf("got skipped lines %v, want %v", gotPerrs, wantPerrs)
+	}
+}
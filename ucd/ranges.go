@@ -0,0 +1,153 @@
+package ucd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// valueRange is a range of characters of a UCD file like Scripts.txt that
+// have the same value.
+type valueRange struct {
+	first, last rune
+	value       string
+}
+
+// valueRanges are the ranges of a UCD file, sorted by their first
+// character.
+type valueRanges []valueRange
+
+func (v valueRanges) lookup(r rune) string {
+	i := sort.Search(len(v), func(i int) bool { return v[i].last >= r })
+	if i < len(v) && v[i].first <= r {
+		return v[i].value
+	}
+	return ""
+}
+
+// Script returns the Script property of r in Scripts.txt, like Latin or
+// Common, or Unknown for the characters it does not list.
+func Script(r rune) (string, error) {
+	return DefaultCache.Script(r)
+}
+
+// Script is like the package level Script, but uses the files of c.
+func (c *Cache) Script(r rune) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadScripts(context.Background()); err != nil {
+		return "", err
+	}
+	if s := c.scripts.lookup(r); s != "" {
+		return s, nil
+	}
+	return "Unknown", nil
+}
+
+// loadScripts reads Scripts.txt into c.scripts the first time, c.mu must be
+// held.
+func (c *Cache) loadScripts(ctx context.Context) error {
+	if c.scripts != nil {
+		return nil
+	}
+	v, err := c.loadRanges(ctx, scriptsFile)
+	if err != nil {
+		return err
+	}
+	c.scripts = v
+	return nil
+}
+
+// loadRanges returns the parsed ranges of the UCD file name.
+func (c *Cache) loadRanges(ctx context.Context, name string) (v valueRanges, err error) {
+	err = c.parseFile(ctx, "", name, func(r io.Reader) (perrs ParseErrors, err error) {
+		v, perrs, err = parseRanges(r, name)
+		return perrs, err
+	})
+	return v, err
+}
+
+// parseRanges returns the ranges of the UCD file name read from r, whose
+// lines look like those of Scripts.txt and DerivedAge.txt:
+//
+//	0041..005A    ; Latin # L&  [26] LATIN CAPITAL LETTER A..LATIN CAPITAL LETTER Z
+//	00AA          ; 1.1 #       FEMININE ORDINAL INDICATOR
+func parseRanges(r io.Reader, name string) (valueRanges, ParseErrors, error) {
+	var v valueRanges
+	var perrs ParseErrors
+	var lineNr int
+	rdr := bufio.NewScanner(r)
+	rdr.Buffer(nil, maxLineSize)
+	for rdr.Scan() {
+		lineNr++
+		line := rdr.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) != 2 {
+			perrs.add(name, lineNr, fmt.Errorf("invalid format, expected 2 fields, got %d", len(fields)))
+			continue
+		}
+		codes := strings.SplitN(strings.TrimSpace(fields[0]), "..", 2)
+		first, err := strconv.ParseInt(codes[0], 16, 32)
+		if err != nil {
+			perrs.add(name, lineNr, fmt.Errorf("invalid rune %q: %w", codes[0], err))
+			continue
+		}
+		last := first
+		if len(codes) == 2 {
+			if last, err = strconv.ParseInt(codes[1], 16, 32); err != nil {
+				perrs.add(name, lineNr, fmt.Errorf("invalid rune %q: %w", codes[1], err))
+				continue
+			}
+		}
+		v = append(v, valueRange{rune(first), rune(last), strings.TrimSpace(fields[1])})
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	sort.Slice(v, func(i, j int) bool {
+		return v[i].first < v[j].first
+	})
+	return v, perrs, nil
+}
+
+// newerOrSame reports whether the Unicode version age, like "15.0", is the
+// same as or later than since. Versions that are not numbers are never.
+func newerOrSame(age string, since []int) bool {
+	v, ok := parseVersion(age)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(since); i++ {
+		n := 0
+		if i < len(v) {
+			n = v[i]
+		}
+		if n != since[i] {
+			return n > since[i]
+		}
+	}
+	return true
+}
+
+// parseVersion returns the numbers of a version like "15.0".
+func parseVersion(s string) ([]int, bool) {
+	var v []int
+	for _, f := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		v = append(v, n)
+	}
+	return v, true
+}
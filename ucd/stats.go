@@ -0,0 +1,30 @@
+package ucd
+
+import "time"
+
+// Stats are how long a Cache spent on getting the NamesList ready to search.
+type Stats struct {
+	// Download is the time spent downloading UCD files.
+	Download time.Duration
+	// Parse is the time spent reading and parsing the NamesList, or reading
+	// its index.
+	Parse time.Duration
+	// Lines is the number of lines of the NamesList that were parsed, none
+	// if it was read from its index.
+	Lines int
+	// Index reports whether the NamesList was read from its index.
+	Index bool
+}
+
+// Stats returns the Stats of the files c has read so far.
+func (c *Cache) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+func (c *Cache) addStats(fn func(s *Stats)) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	fn(&c.stats)
+}
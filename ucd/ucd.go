@@ -0,0 +1,1083 @@
+// Package ucd searches the character names of the Unicode Character Database.
+package ucd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+)
+
+// Category is a block of the NamesList.
+type Category struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Description is the notices at the start of the block, joined by
+	// spaces.
+	Description string `json:"description"`
+}
+
+// CodePoint is a character and the description lines the NamesList has for it.
+type CodePoint struct {
+	Chr rune `json:"chr"`
+	// Name is the name as it is in the NamesList, for display. The search
+	// matches Desc and FullDesc, whose lines have their spaces trimmed and
+	// collapsed, and are lowercased unless the search is case sensitive.
+	Name        string   `json:"name"`
+	Desc        string   `json:"desc"`
+	FullDesc    []string `json:"full_desc"`
+	Category    Category `json:"category"`
+	Subcategory string   `json:"subcategory"`
+	// Aliases, Comments and CrossRefs are the annotation lines of FullDesc
+	// starting with "=" or "%", "*" and "x", without that symbol.
+	Aliases   []string `json:"aliases,omitempty"`
+	Comments  []string `json:"comments,omitempty"`
+	CrossRefs []string `json:"cross_refs,omitempty"`
+	// GeneralCategory, BidiClass and NumericValue are the properties of
+	// UnicodeData.txt, only set if the search asked for them.
+	GeneralCategory string `json:"general_category,omitempty"`
+	BidiClass       string `json:"bidi_class,omitempty"`
+	NumericValue    string `json:"numeric_value,omitempty"`
+	// Script is the Script property of Scripts.txt, like Latin or
+	// Old_Italic, only set if the search asked for it.
+	Script string `json:"script,omitempty"`
+	// Age is the version of Unicode the character was added in, like 15.0,
+	// of DerivedAge.txt, only set if the search asked for it.
+	Age string `json:"age,omitempty"`
+	// IsEmoji is set for the entries of emoji-test.txt returned by
+	// SearchEmojiFunc. Emoji made of more than one code point, like flags
+	// and ZWJ sequences, have all of them in Sequence, Chr is the first.
+	IsEmoji  bool   `json:"is_emoji,omitempty"`
+	Sequence string `json:"sequence,omitempty"`
+	// Decomposition is the canonical decomposition of the ":" line, or the
+	// compatibility decomposition of the "#" line, whose formatting tag,
+	// like "<noBreak>", is DecompositionTag.
+	Decomposition    []rune `json:"decomposition,omitempty"`
+	DecompositionTag string `json:"decomposition_tag,omitempty"`
+}
+
+// String returns the character, or the whole sequence of an emoji made of
+// more than one code point.
+func (c CodePoint) String() string {
+	if c.Sequence != "" {
+		return c.Sequence
+	}
+	return string(c.Chr)
+}
+
+// newCodePoint returns the entry for chr, with its name and annotations
+// taken from fullDesc.
+func newCodePoint(chr rune, fullDesc []string, cat Category, subcat string) CodePoint {
+	name := fullDesc[0]
+	for i, line := range fullDesc {
+		fullDesc[i] = collapseSpace(line)
+	}
+	c := CodePoint{Chr: chr, Name: name, Desc: fullDesc[0], FullDesc: fullDesc, Category: cat, Subcategory: subcat}
+	for _, line := range fullDesc[1:] {
+		if len(line) < 2 || line[1] != ' ' {
+			continue
+		}
+		switch line[0] {
+		case '=', '%':
+			c.Aliases = append(c.Aliases, line[2:])
+		case '*':
+			c.Comments = append(c.Comments, line[2:])
+		case 'x':
+			c.CrossRefs = append(c.CrossRefs, line[2:])
+		case ':', '#':
+			c.DecompositionTag, c.Decomposition = parseDecomposition(line[2:])
+		}
+	}
+	return c
+}
+
+// collapseSpace returns s without leading and trailing white space and with
+// runs of it inside replaced by a single space. Most lines have none of
+// that and are returned as they are.
+func collapseSpace(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\t' || s[i] == ' ' && (i == 0 || i == len(s)-1 || s[i+1] == ' ') || s[i] >= utf8.RuneSelf {
+			return strings.Join(strings.Fields(s), " ")
+		}
+	}
+	return s
+}
+
+// parseDecomposition returns the formatting tag and the code points of a
+// decomposition like "<noBreak> 0020", or no code points if it is invalid.
+func parseDecomposition(s string) (string, []rune) {
+	var tag string
+	var d []rune
+	for _, f := range strings.Fields(s) {
+		if strings.HasPrefix(f, "<") && d == nil {
+			tag = f
+			continue
+		}
+		i, err := strconv.ParseInt(f, 16, 32)
+		if err != nil {
+			return "", nil
+		}
+		d = append(d, rune(i))
+	}
+	return tag, d
+}
+
+// MarshalJSON encodes Chr as the character itself, alongside its U+XXXX code point.
+func (c CodePoint) MarshalJSON() ([]byte, error) {
+	type codePoint CodePoint
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(struct {
+		Chr       string `json:"chr"`
+		CodePoint string `json:"codepoint"`
+		codePoint
+	}{c.String(), fmt.Sprintf("%U", c.Chr), codePoint(c)})
+	return bytes.TrimSpace(buf.Bytes()), err
+}
+
+// warnf reports a problem that does not stop the search to c.Warnings.
+func (c *Cache) warnf(format string, args ...interface{}) {
+	if c.Warnings != nil {
+		fmt.Fprintf(c.Warnings, format, args...)
+	}
+}
+
+// ParseError is a line of a UCD file that could not be parsed.
+type ParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors are the lines of a UCD file that were skipped because they
+// could not be parsed. The parsers return them along with the rest of the
+// file, and parseFile reports them.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more)", e[0], len(e)-1)
+}
+
+func (e *ParseErrors) add(file string, line int, err error) {
+	*e = append(*e, &ParseError{file, line, err})
+}
+
+// skipped reports the lines of a UCD file that could not be parsed. They
+// are returned as an error if c is Strict, and otherwise only counted in a
+// warning.
+func (c *Cache) skipped(perrs ParseErrors) error {
+	if len(perrs) == 0 {
+		return nil
+	}
+	if c.Strict {
+		return perrs
+	}
+	c.warnf("skipped %d invalid lines of %s, the first is %s\n", len(perrs), perrs[0].File, perrs[0])
+	return nil
+}
+
+// parseFile parses the UCD file name, or the file local instead if it is
+// set, with parse and reports the lines it skipped.
+func (c *Cache) parseFile(ctx context.Context, local, name string, parse func(io.Reader) (ParseErrors, error)) error {
+	f, err := c.openLocal(ctx, local, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	perrs, err := parse(f)
+	if err != nil {
+		return err
+	}
+	return c.skipped(perrs)
+}
+
+// SearchIndex returns the entries of Index.txt, the index of the Unicode
+// Standard, whose name matches every word of search.
+func SearchIndex(search string) ([]CodePoint, error) {
+	return DefaultCache.SearchIndex(search)
+}
+
+// SearchIndex is like the package level SearchIndex, but uses the files of
+// c.
+func (c *Cache) SearchIndex(search string) (cp []CodePoint, err error) {
+	err = c.SearchIndexContext(context.Background(), search, Options{}, func(m CodePoint) error {
+		cp = append(cp, m)
+		return nil
+	})
+	return cp, err
+}
+
+// SearchIndexContext is like SearchContext, but searches the names of
+// Index.txt, which has the terms the Unicode Standard files characters
+// under, instead of the NamesList. Each character is found once, even if
+// more than one of its names match.
+func SearchIndexContext(ctx context.Context, search string, opts Options, fn func(CodePoint) error) error {
+	return DefaultCache.SearchIndexContext(ctx, search, opts, fn)
+}
+
+// SearchIndexContext is like the package level SearchIndexContext, but uses
+// the files of c.
+func (c *Cache) SearchIndexContext(ctx context.Context, search string, opts Options, fn func(CodePoint) error) error {
+	return searchEntries(ctx, c.loadIndex, search, opts, fn)
+}
+
+func (c *Cache) loadIndex(ctx context.Context) (cp []CodePoint, err error) {
+	err = c.parseFile(ctx, c.Index, indexFile, func(r io.Reader) (perrs ParseErrors, err error) {
+		cp, perrs, err = parseIndex(r)
+		return perrs, err
+	})
+	return cp, err
+}
+
+// parseIndex returns the entries of the Index.txt read from r, whose lines
+// look like:
+//
+//	A WITH ACUTE, LATIN CAPITAL LETTER	00C1
+func parseIndex(r io.Reader) ([]CodePoint, ParseErrors, error) {
+	var cp []CodePoint
+	buf := bufio.NewScanner(r)
+	buf.Buffer(nil, maxLineSize)
+	var perrs ParseErrors
+	var lineNr int
+	for buf.Scan() {
+		lineNr++
+		parts := strings.Split(buf.Text(), "\t")
+		if len(parts) != 2 {
+			perrs.add(indexFile, lineNr, fmt.Errorf("invalid format, expected 2 fields, got %d", len(parts)))
+			continue
+		}
+		chr, err := strconv.ParseInt(parts[1], 16, 32)
+		if err != nil {
+			perrs.add(indexFile, lineNr, fmt.Errorf("invalid rune %q: %w", parts[1], err))
+			continue
+		}
+		cp = append(cp, newCodePoint(rune(chr), []string{parts[0]}, Category{}, ""))
+	}
+	if err := buf.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %w", indexFile, err)
+	}
+	return cp, perrs, nil
+}
+
+// splitTerms splits query into words, except that text between double
+// quotes is kept together as a single term.
+func splitTerms(query string) []string {
+	var terms []string
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 0 {
+			terms = append(terms, strings.Fields(part)...)
+		} else if phrase := strings.Join(strings.Fields(part), " "); phrase != "" {
+			terms = append(terms, phrase)
+		}
+	}
+	return terms
+}
+
+// term is a word or phrase of a query.
+type term struct {
+	text string
+	// not is set for terms that must not match.
+	not bool
+}
+
+// parseQuery splits query into alternatives separated by "|", each a list
+// of terms that must all match, like splitTerms does. A term starting with
+// "-" must not match.
+func parseQuery(query string) [][]term {
+	alts := [][]term{nil}
+	var not bool
+	add := func(text string) {
+		alts[len(alts)-1] = append(alts[len(alts)-1], term{text, not})
+		not = false
+	}
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.Join(strings.Fields(part), " "); phrase != "" {
+				add(phrase)
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			for j, word := range strings.Split(field, "|") {
+				if j > 0 {
+					alts = append(alts, nil)
+					not = false
+				}
+				if strings.HasPrefix(word, "-") {
+					not = true
+					word = word[1:]
+				}
+				if word != "" {
+					add(word)
+				}
+			}
+		}
+	}
+	q := alts[:0]
+	for _, alt := range alts {
+		if len(alt) > 0 {
+			q = append(q, alt)
+		}
+	}
+	return q
+}
+
+// Mode selects how SearchWith matches the search.
+type Mode int
+
+const (
+	// Substring matches entries containing every word of the search. Words
+	// between double quotes are matched together as a phrase, a word
+	// starting with "-" must not be contained, and "|" separates
+	// alternatives, as in: latin -small | cyrillic.
+	Substring Mode = iota
+	// Words matches entries containing every word of the search as a whole
+	// word, with the operators of Substring.
+	Words
+	// Regexp matches the search as a regular expression against the name,
+	// or each description line with Options.AnyLine.
+	Regexp
+	// Exact matches entries whose name or one of whose aliases equals the
+	// search.
+	Exact
+	// Fuzzy matches entries with words within a small edit distance of the
+	// words of the search, and returns the closest matches first.
+	Fuzzy
+	// Prefix matches entries whose name starts with the search, and returns
+	// them sorted by name.
+	Prefix
+)
+
+// Options control how SearchWith matches.
+type Options struct {
+	// CaseSensitive disables lowercasing the search and the description
+	// lines, which are then returned in their original case.
+	CaseSensitive bool
+	Mode          Mode
+	// Category only matches entries of the blocks whose name contains it,
+	// ignoring case.
+	Category string
+	// Subcategory only matches entries of the subcategories whose name
+	// contains it, ignoring case.
+	Subcategory string
+	// GeneralCategory only matches entries whose general category, like Lu,
+	// starts with it, so L matches all letters. It makes the search read
+	// UnicodeData.txt.
+	GeneralCategory string
+	// Script only matches entries of the script, like Cyrillic, ignoring
+	// case and with spaces for the underscores of names like Old_Italic. It
+	// makes the search read Scripts.txt.
+	Script string
+	// Since only matches entries added in this version of Unicode, like
+	// 15.0, or later. It makes the search read DerivedAge.txt.
+	Since string
+	// AnyLine matches the search against every description line, like the
+	// aliases and comments, and the names of the block and subcategory,
+	// instead of only the name. Without it only Exact matches aliases.
+	AnyLine bool
+}
+
+// hasWord reports whether one of the words of s is equal to word, ignoring
+// punctuation around the words of s, as in "(lf)". A word with spaces, from
+// a quoted phrase, must equal consecutive words of s.
+func hasWord(s, word string, equal func(a, b string) bool) bool {
+	words := strings.Fields(word)
+	fields := strings.Fields(s)
+	for i := 0; len(words) > 0 && i+len(words) <= len(fields); i++ {
+		j := 0
+		for j < len(words) && equal(strings.TrimFunc(fields[i+j], isPunct), words[j]) {
+			j++
+		}
+		if j == len(words) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPunct(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Search returns the NamesList entries whose name matches every word of
+// search. An empty search matches everything.
+func Search(search string) ([]CodePoint, error) {
+	return SearchWith(search, Options{})
+}
+
+// SearchWith is like Search but matches according to opts.
+func SearchWith(search string, opts Options) ([]CodePoint, error) {
+	return DefaultCache.SearchWith(search, opts)
+}
+
+// SearchWith is like the package level SearchWith, but uses the files of c.
+func (c *Cache) SearchWith(search string, opts Options) (cp []CodePoint, err error) {
+	err = c.SearchContext(context.Background(), search, opts, func(m CodePoint) error {
+		cp = append(cp, m)
+		return nil
+	})
+	return cp, err
+}
+
+// SearchFunc is like SearchWith but calls fn for each match as it is found,
+// instead of returning them all at once. Fuzzy searches only call fn once
+// all matches are ranked. If fn returns an error, the search stops and
+// SearchFunc returns that error.
+func SearchFunc(search string, opts Options, fn func(CodePoint) error) error {
+	return SearchContext(context.Background(), search, opts, fn)
+}
+
+// SearchContext is like SearchFunc, but stops downloading, parsing and
+// searching when ctx is done and returns its error.
+func SearchContext(ctx context.Context, search string, opts Options, fn func(CodePoint) error) error {
+	return DefaultCache.SearchContext(ctx, search, opts, fn)
+}
+
+// SearchContext is like the package level SearchContext, but uses the files
+// of c.
+func (c *Cache) SearchContext(ctx context.Context, search string, opts Options, fn func(CodePoint) error) error {
+	return searchEntries(ctx, func(ctx context.Context) ([]CodePoint, error) {
+		props := c.Properties
+		return c.loadNamesList(ctx, props&PropUnicodeData != 0 || opts.GeneralCategory != "",
+			props&PropScript != 0 || opts.Script != "", props&PropAge != 0 || opts.Since != "")
+	}, search, opts, fn)
+}
+
+// SearchReader is like SearchWith, but searches the NamesList read from r
+// instead of the one of DefaultCache, and without the properties of
+// UnicodeData.txt. The lines that could not be parsed are skipped and
+// returned as ParseErrors along with the matches.
+func SearchReader(r io.Reader, search string, opts Options) ([]CodePoint, error) {
+	var all, cp []CodePoint
+	perr := parseNamesList(r, func(c CodePoint) {
+		all = append(all, c)
+	})
+	var perrs ParseErrors
+	if perr != nil && !errors.As(perr, &perrs) {
+		return nil, perr
+	}
+	err := searchEntries(context.Background(), func(context.Context) ([]CodePoint, error) {
+		return all, nil
+	}, search, opts, func(c CodePoint) error {
+		cp = append(cp, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(perrs) > 0 {
+		return cp, perrs
+	}
+	return cp, nil
+}
+
+// searchEntries calls fn for the entries returned by load that match
+// search, until ctx is done.
+func searchEntries(ctx context.Context, load func(context.Context) ([]CodePoint, error), search string, opts Options, fn func(CodePoint) error) error {
+	m, err := newMatcher(search, opts)
+	if err != nil {
+		return err
+	}
+	all, err := load(ctx)
+	if err != nil {
+		return err
+	}
+	if !m.hasCategory(all) {
+		return filterError(opts)
+	}
+	// A character is only emitted once, even if it is listed more than once.
+	// Emoji sequences share their first character, so they are told apart
+	// by the whole sequence.
+	seen := make(map[string]bool)
+	emit := func(c CodePoint) error {
+		if seen[c.String()] {
+			return nil
+		}
+		seen[c.String()] = true
+		if !opts.CaseSensitive {
+			c = lower(c)
+		}
+		return fn(c)
+	}
+	if opts.Mode == Fuzzy || opts.Mode == Prefix {
+		for i, c := range all {
+			if i%checkEvery == 0 && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			m.add(c)
+		}
+		for _, c := range m.results() {
+			if err := emit(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, c := range all {
+		if i%checkEvery == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, ok := m.match(c); !ok {
+			continue
+		}
+		if err := emit(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterError returns the error for filters of opts that no entry matches.
+func filterError(opts Options) error {
+	var filters []string
+	if opts.Category != "" {
+		filters = append(filters, fmt.Sprintf("category %q", opts.Category))
+	}
+	if opts.Subcategory != "" {
+		filters = append(filters, fmt.Sprintf("subcategory %q", opts.Subcategory))
+	}
+	if opts.GeneralCategory != "" {
+		filters = append(filters, fmt.Sprintf("general category %q", opts.GeneralCategory))
+	}
+	if opts.Script != "" {
+		filters = append(filters, fmt.Sprintf("script %q", opts.Script))
+	}
+	if opts.Since != "" {
+		filters = append(filters, fmt.Sprintf("Unicode %s or later", opts.Since))
+	}
+	return fmt.Errorf("no characters in %s", strings.Join(filters, " and "))
+}
+
+// lower returns c with its description lines in lower case.
+func lower(c CodePoint) CodePoint {
+	c.Desc = strings.ToLower(c.Desc)
+	c.FullDesc = lowerAll(c.FullDesc)
+	c.Aliases = lowerAll(c.Aliases)
+	c.Comments = lowerAll(c.Comments)
+	c.CrossRefs = lowerAll(c.CrossRefs)
+	return c
+}
+
+func lowerAll(lines []string) []string {
+	if lines == nil {
+		return nil
+	}
+	lower := make([]string, len(lines))
+	for i, l := range lines {
+		lower[i] = strings.ToLower(l)
+	}
+	return lower
+}
+
+// Filter returns the entries of cp that match search according to opts.
+func Filter(cp []CodePoint, search string, opts Options) ([]CodePoint, error) {
+	m, err := newMatcher(search, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cp {
+		m.add(c)
+	}
+	return m.results(), nil
+}
+
+// matcher collects the code points that match a search.
+type matcher struct {
+	search string
+	terms  []string
+	query  [][]term
+	mode   Mode
+	fold   bool
+	re     *regexp.Regexp
+	cat    string
+	subcat string
+	gc     string
+	script string
+	any    bool
+	since  []int
+	cp     []CodePoint
+	scores []int
+}
+
+func newMatcher(search string, opts Options) (*matcher, error) {
+	m := &matcher{
+		search: search,
+		mode:   opts.Mode,
+		fold:   !opts.CaseSensitive,
+		cat:    foldCase(opts.Category),
+		subcat: foldCase(opts.Subcategory),
+		gc:     opts.GeneralCategory,
+		script: strings.ReplaceAll(opts.Script, " ", "_"),
+		any:    opts.AnyLine,
+	}
+	if opts.Since != "" {
+		since, ok := parseVersion(opts.Since)
+		if !ok {
+			return nil, fmt.Errorf("invalid Unicode version %q, expected one like 15.0", opts.Since)
+		}
+		m.since = since
+	}
+	if m.fold {
+		m.search = foldCase(search)
+	}
+	m.terms = splitTerms(m.search)
+	m.query = parseQuery(m.search)
+	if opts.Mode == Regexp {
+		expr := search
+		if m.fold {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// contains reports whether s contains term, which is already case folded
+// when folding.
+func (m *matcher) contains(s, term string) bool {
+	if m.fold {
+		return containsFold(s, term)
+	}
+	return strings.Contains(s, term)
+}
+
+func (m *matcher) equal(s, term string) bool {
+	if m.fold {
+		return equalFold(s, term)
+	}
+	return s == term
+}
+
+func (m *matcher) equalAlias(c CodePoint) bool {
+	for _, a := range c.Aliases {
+		if m.equal(a, m.search) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLines reports whether one of the alternatives of the query matches:
+// all its terms are in one of targets and none of its negated terms is in
+// any of them.
+func (m *matcher) matchLines(targets ...[]string) bool {
+	if m.mode == Regexp {
+		for _, target := range targets {
+			for _, t := range target {
+				if m.re.MatchString(t) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, alt := range m.query {
+		if m.matchAlt(alt, targets) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *matcher) matchAlt(alt []term, targets [][]string) bool {
+	for _, t := range alt {
+		if !t.not {
+			continue
+		}
+		for _, target := range targets {
+			if m.has(target, t.text) {
+				return false
+			}
+		}
+	}
+	for _, target := range targets {
+		match := true
+		for _, t := range alt {
+			if !t.not && !m.has(target, t.text) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// has reports whether one of target contains term, as a whole word in Words
+// mode.
+func (m *matcher) has(target []string, term string) bool {
+	for _, t := range target {
+		if m.mode == Words && hasWord(t, term, m.equal) || m.mode != Words && m.contains(t, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCategory reports whether any of cp is in a category, subcategory and
+// general category m matches.
+func (m *matcher) hasCategory(cp []CodePoint) bool {
+	for _, c := range cp {
+		if m.inCategory(c) {
+			return true
+		}
+	}
+	return m.cat == "" && m.subcat == "" && m.gc == "" && m.script == "" && m.since == nil
+}
+
+func (m *matcher) inCategory(c CodePoint) bool {
+	return (m.cat == "" || containsFold(c.Category.Name, m.cat)) &&
+		(m.subcat == "" || containsFold(c.Subcategory, m.subcat)) &&
+		strings.HasPrefix(c.GeneralCategory, m.gc) &&
+		(m.script == "" || strings.EqualFold(c.Script, m.script)) &&
+		(m.since == nil || newerOrSame(c.Age, m.since))
+}
+
+// match reports whether c matches, and for fuzzy matches how closely.
+func (m *matcher) match(c CodePoint) (score int, ok bool) {
+	switch {
+	case !m.inCategory(c):
+		return 0, false
+	case m.search == "":
+		return 0, true
+	case m.mode == Exact:
+		return 0, m.equal(c.Desc, m.search) || m.equalAlias(c)
+	case m.mode == Prefix:
+		if m.fold {
+			return 0, hasPrefixFold(c.Desc, m.search)
+		}
+		return 0, strings.HasPrefix(c.Desc, m.search)
+	case m.mode == Fuzzy:
+		target := []string{c.Desc}
+		if m.any {
+			target = append(c.FullDesc[:len(c.FullDesc):len(c.FullDesc)], c.Category.Name, c.Subcategory)
+		}
+		if m.fold {
+			folded := make([]string, len(target))
+			for i, t := range target {
+				folded[i] = foldCase(t)
+			}
+			target = folded
+		}
+		return fuzzyScore(target, m.terms)
+	case !m.any:
+		return 0, m.matchLines([]string{c.Desc})
+	}
+	return 0, m.matchLines(c.FullDesc, []string{c.Category.Name, c.Subcategory})
+}
+
+func (m *matcher) add(c CodePoint) {
+	if score, ok := m.match(c); ok {
+		m.cp = append(m.cp, c)
+		m.scores = append(m.scores, score)
+	}
+}
+
+func (m *matcher) results() []CodePoint {
+	switch m.mode {
+	case Fuzzy:
+		sort.Stable(ranked{m.cp, m.scores})
+	case Prefix:
+		sort.SliceStable(m.cp, func(i, j int) bool {
+			if m.fold {
+				return foldCase(m.cp[i].Desc) < foldCase(m.cp[j].Desc)
+			}
+			return m.cp[i].Desc < m.cp[j].Desc
+		})
+	}
+	return m.cp
+}
+
+// containsFold reports whether s contains substr, which is case folded,
+// ignoring the case of s. ASCII strings, like most of the NamesList, are compared
+// without allocating.
+func containsFold(s, substr string) bool {
+	if !isASCII(s) {
+		return strings.Contains(foldCase(s), substr)
+	}
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		j := 0
+		for j < n && toLowerASCII(s[i+j]) == substr[j] {
+			j++
+		}
+		if j == n {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixFold reports whether s starts with prefix, which is case folded,
+// ignoring the case of s.
+func hasPrefixFold(s, prefix string) bool {
+	if !isASCII(s) {
+		return strings.HasPrefix(foldCase(s), prefix)
+	}
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if toLowerASCII(s[i]) != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalFold reports whether s equals term, which is case folded, ignoring
+// the case of s.
+func equalFold(s, term string) bool {
+	if isASCII(s) && isASCII(term) {
+		return strings.EqualFold(s, term)
+	}
+	return foldCase(s) == term
+}
+
+// foldCase returns s case folded, so that for instance "STRASSE" and
+// "Straße" are the same and "ǅ" is "ǆ". Strings in ASCII, like the names of
+// the NamesList, are only lowercased.
+func foldCase(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+	return cases.Fold().String(s)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func toLowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// checkEvery is how many entries or lines are searched or parsed between
+// checks whether the context is done.
+const checkEvery = 1 << 12
+
+// maxLineSize is the length of the longest line of a UCD file that can be
+// read. The lines of the current files are far shorter, but later versions
+// may have longer ones than the 64KB a bufio.Scanner allows by default.
+const maxLineSize = 1 << 20
+
+// parseNamesList calls fn for every entry of the NamesList read from r, and
+// returns the lines it skipped as ParseErrors.
+func parseNamesList(r io.Reader, fn func(CodePoint)) error {
+	p := namesListParser{ctx: context.Background(), fn: fn}
+	return p.parse(r, true)
+}
+
+// namesListParser parses the lines of a NamesList, or of a part of one that
+// starts at line lineNr+1. A line "@@\tstart\tname\tend" starts a block, a
+// line "@\t\tname" a subcategory of it and "@+\t\ttext" lines before its
+// first entry are its description. An entry is a line "code\tname", followed
+// by lines "\tannotation" that are added to its FullDesc. Other lines, like
+// ";" comments, "\t\t" continuations and the remaining "@" lines, are
+// ignored.
+type namesListParser struct {
+	ctx    context.Context
+	fn     func(CodePoint)
+	lineNr int
+	perrs  ParseErrors
+
+	category    Category
+	subcategory string
+	// blocks are the blocks of the valid block headers, also the ones
+	// without entries.
+	blocks []Category
+	// inHeader is set until the first entry of a block, notices after it
+	// are about the entries rather than the block.
+	inHeader bool
+}
+
+// isRangeBound reports whether name is the one of the first or last code
+// point of a range, like "<Low Surrogate, First>".
+func isRangeBound(name string) bool {
+	return strings.HasPrefix(name, "<") && (strings.HasSuffix(name, ", First>") || strings.HasSuffix(name, ", Last>"))
+}
+
+func (p *namesListParser) parse(r io.Reader, first bool) error {
+	var schr string
+	var schrLine int
+	var ccat Category
+	var cscat string
+	emit := func(desc []string) {
+		i, err := strconv.ParseInt(schr, 16, 32)
+		if err != nil {
+			p.perrs.add(namesListFile, schrLine, fmt.Errorf("invalid rune %q: %w", schr, err))
+			return
+		}
+		if !utf8.ValidRune(rune(i)) {
+			// The surrogate blocks only list their first and last code
+			// point, which are not characters but are not a mistake
+			// either.
+			if isRangeBound(desc[0]) {
+				return
+			}
+			// string(r) of a surrogate would be U+FFFD instead.
+			p.perrs.add(namesListFile, schrLine, fmt.Errorf("invalid rune %q, it is not a character", schr))
+			return
+		}
+		fullDesc := append([]string(nil), desc...)
+		p.fn(newCodePoint(rune(i), fullDesc, ccat, cscat))
+	}
+	rdr := bufio.NewScanner(r)
+	rdr.Buffer(nil, maxLineSize)
+	desc := make([]string, 0, 5)
+	for rdr.Scan() {
+		p.lineNr++
+		if p.lineNr%checkEvery == 0 && p.ctx.Err() != nil {
+			return p.ctx.Err()
+		}
+		line := rdr.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		if strings.HasPrefix(line, "@\t\t") {
+			p.subcategory = line[3:]
+			continue
+		}
+		if strings.HasPrefix(line, "@@\t") {
+			// Nothing of the block before carries over, even if the
+			// header is invalid and its entries have no block.
+			p.category = Category{}
+			p.subcategory = ""
+			parts := strings.Split(line, "\t")
+			if len(parts) != 4 {
+				p.perrs.add(namesListFile, p.lineNr, fmt.Errorf("invalid block header, expected 4 fields, got %d", len(parts)))
+				continue
+			}
+			p.category = Category{Name: parts[2], Start: parts[1], End: parts[3]}
+			p.blocks = append(p.blocks, p.category)
+			p.inHeader = true
+			continue
+		}
+		if strings.HasPrefix(line, "@+\t\t") {
+			if p.inHeader {
+				p.category.Description = strings.TrimSpace(p.category.Description + " " + line[4:])
+				p.blocks[len(p.blocks)-1] = p.category
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ";") || strings.HasPrefix(line, "@") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 {
+			p.perrs.add(namesListFile, p.lineNr, fmt.Errorf("invalid format, expected 2 fields, got %d", len(parts)))
+			continue
+		}
+		if parts[0] != "" {
+			if schr != "" {
+				emit(desc)
+			}
+			schr = parts[0]
+			schrLine = p.lineNr
+			desc = desc[0:0]
+			p.inHeader = false
+			ccat = p.category
+			cscat = p.subcategory
+		}
+		desc = append(desc, parts[1])
+	}
+	if err := rdr.Err(); err != nil {
+		return fmt.Errorf("could not read %s: %w", namesListFile, err)
+	}
+	if schr != "" {
+		emit(desc)
+	}
+	if len(p.perrs) > 0 {
+		return p.perrs
+	}
+	return nil
+}
+
+// Names maps characters to their NamesList entry.
+type Names map[rune]CodePoint
+
+// LoadNames returns every entry of the NamesList.
+func LoadNames() (Names, error) {
+	return DefaultCache.LoadNames()
+}
+
+// LoadNames is like the package level LoadNames, but uses the files of c.
+func (c *Cache) LoadNames() (Names, error) {
+	cp, err := c.SearchWith("", Options{})
+	if err != nil {
+		return nil, err
+	}
+	names := make(Names, len(cp))
+	for _, m := range cp {
+		names[m.Chr] = m
+	}
+	return names, nil
+}
+
+// Lookup returns the NamesList entry of r.
+func (n Names) Lookup(r rune) (CodePoint, bool) {
+	c, ok := n[r]
+	return c, ok
+}
+
+// Blocks returns the blocks of the NamesList in the order of the file, which
+// is by their range.
+func Blocks() ([]Category, error) {
+	return DefaultCache.Blocks()
+}
+
+// Blocks is like the package level Blocks, but uses the files of c.
+func (c *Cache) Blocks() ([]Category, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nl, err := c.parsedNamesList(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var blocks []Category
+	for _, b := range nl.blocks {
+		if !c.excluded(b.Name) {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
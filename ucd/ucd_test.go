@@ -0,0 +1,308 @@
+package ucd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testNamesList = "testdata/NamesList.txt"
+
+// readFixture returns every entry of the NamesList fixture.
+func readFixture(t testing.TB) []CodePoint {
+	t.Helper()
+	f, err := os.Open(testNamesList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var cp []CodePoint
+	parseNamesList(f, func(c CodePoint) {
+		cp = append(cp, c)
+	})
+	return cp
+}
+
+// chars returns the characters of cp.
+func chars(cp []CodePoint) []rune {
+	var r []rune
+	for _, c := range cp {
+		r = append(r, c.Chr)
+	}
+	return r
+}
+
+func TestParseNamesListFixture(t *testing.T) {
+	basicLatin := Category{"C0 Controls and Basic Latin (Basic Latin)", "0000", "007F", "The C0 controls and ASCII."}
+	runic := Category{"Runic", "16A0", "16FF", ""}
+	arrows := Category{"Arrows", "2190", "21FF", ""}
+	emoticons := Category{"Emoticons", "1F600", "1F64F", ""}
+	want := []CodePoint{
+		{Chr: 0x000A, Name: "<control>", Desc: "<control>", Category: basicLatin, Subcategory: "C0 controls",
+			FullDesc: []string{"<control>", "= LINE FEED (LF)", "= new line (NL), end of line (EOL)"},
+			Aliases:  []string{"LINE FEED (LF)", "new line (NL), end of line (EOL)"}},
+		{Chr: '!', Name: "EXCLAMATION MARK", Desc: "EXCLAMATION MARK", Category: basicLatin, Subcategory: "ASCII punctuation and symbols",
+			FullDesc:  []string{"EXCLAMATION MARK", "= factorial", "x 00A1"},
+			Aliases:   []string{"factorial"},
+			CrossRefs: []string{"00A1"}},
+		{Chr: 'A', Name: "LATIN CAPITAL LETTER A", Desc: "LATIN CAPITAL LETTER A", Category: basicLatin, Subcategory: "Uppercase Latin alphabet",
+			FullDesc: []string{"LATIN CAPITAL LETTER A"}},
+		{Chr: 0x16A0, Name: "RUNIC LETTER FEHU FEOH FE F", Desc: "RUNIC LETTER FEHU FEOH FE F", Category: runic, Subcategory: "Runic letters",
+			FullDesc: []string{"RUNIC LETTER FEHU FEOH FE F"}},
+		{Chr: 0x2190, Name: "LEFTWARDS ARROW", Desc: "LEFTWARDS ARROW", Category: arrows,
+			FullDesc: []string{"LEFTWARDS ARROW"}},
+		{Chr: 0x2192, Name: "RIGHTWARDS ARROW", Desc: "RIGHTWARDS ARROW", Category: arrows, Subcategory: "Simple arrows",
+			FullDesc: []string{"RIGHTWARDS ARROW", "= z notation total function"},
+			Aliases:  []string{"z notation total function"}},
+		{Chr: 0x1F600, Name: "GRINNING FACE", Desc: "GRINNING FACE", Category: emoticons, Subcategory: "Faces",
+			FullDesc: []string{"GRINNING FACE"}},
+	}
+	got := readFixture(t)
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("entry %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSearchExcludesBlocks(t *testing.T) {
+	c := &Cache{BaseDir: t.TempDir(), NamesList: testNamesList, Exclude: DefaultExclude}
+	all, err := c.loadNamesList(context.Background(), false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := Filter(all, "letter", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp) != 1 || cp[0].Chr != 'A' {
+		t.Errorf("got %v, want only U+0041 without the Runic letters", cp)
+	}
+}
+
+func TestAliasNeedsAnyLine(t *testing.T) {
+	tests := []struct {
+		search string
+		opts   Options
+		want   []rune
+	}{
+		{"line feed", Options{}, nil},
+		{"line feed", Options{AnyLine: true}, []rune{0x000A}},
+		{"lf", Options{Mode: Words, AnyLine: true}, []rune{0x000A}},
+		{"factorial", Options{}, nil},
+		{"factorial", Options{Mode: Exact}, []rune{'!'}},
+		{"line feed (lf)", Options{Mode: Exact}, []rune{0x000A}},
+		{"exclamation", Options{Mode: Exact}, nil},
+		{"Factorial", Options{Mode: Exact, CaseSensitive: true}, nil},
+		{"z notation", Options{Mode: Regexp}, nil},
+		{"z notation", Options{Mode: Regexp, AnyLine: true}, []rune{0x2192}},
+	}
+	all := readFixture(t)
+	for _, tt := range tests {
+		cp, err := Filter(all, tt.search, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := chars(cp); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q with %+v: got %U, want %U", tt.search, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestParseNamesListBOM(t *testing.T) {
+	const namesList = "\ufeff@@\t0000\tC0 Controls and Basic Latin (Basic Latin)\t007F\n0041\tLATIN CAPITAL LETTER A\n"
+	var got []CodePoint
+	parseNamesList(strings.NewReader(namesList), func(c CodePoint) {
+		got = append(got, c)
+	})
+	if len(got) != 1 || got[0].Category.Name != "C0 Controls and Basic Latin (Basic Latin)" {
+		t.Errorf("got %+v, want U+0041 in the block of the first line", got)
+	}
+}
+
+func TestParseNamesListLongLine(t *testing.T) {
+	comment := strings.Repeat("long ", 100000)
+	namesList := "0041\tLATIN CAPITAL LETTER A\n\t* " + comment + "\n0042\tLATIN CAPITAL LETTER B\n"
+	var got []rune
+	parseNamesList(strings.NewReader(namesList), func(c CodePoint) {
+		got = append(got, c.Chr)
+	})
+	if want := []rune{'A', 'B'}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %U after a line of %d bytes, want %U", got, len(comment), want)
+	}
+}
+
+func TestSearchEmitsDuplicatesOnce(t *testing.T) {
+	const namesList = "@@\t0000\tBasic Latin\t007F\n0041\tLATIN CAPITAL LETTER A\n" +
+		"@@\t0040\tMore Latin\t007F\n0041\tLATIN CAPITAL LETTER A\n0042\tLATIN CAPITAL LETTER B\n"
+	var all []CodePoint
+	if err := parseNamesList(strings.NewReader(namesList), func(c CodePoint) {
+		all = append(all, c)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Emoji sequences that start with the same character are different
+	// matches.
+	all = append(all,
+		CodePoint{Chr: 0x1F1F3, Sequence: "🇳🇱", Desc: "flag netherlands", FullDesc: []string{"flag netherlands"}},
+		CodePoint{Chr: 0x1F1F3, Sequence: "🇳🇴", Desc: "flag norway", FullDesc: []string{"flag norway"}},
+		CodePoint{Chr: 0x1F1F3, Sequence: "🇳🇱", Desc: "flag netherlands", FullDesc: []string{"flag netherlands"}},
+	)
+	tests := []struct {
+		search string
+		mode   Mode
+		want   []string
+	}{
+		{"latin capital", Substring, []string{"A", "B"}},
+		{"latin capital", Fuzzy, []string{"A", "B"}},
+		{"flag", Substring, []string{"🇳🇱", "🇳🇴"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := searchEntries(context.Background(), func(context.Context) ([]CodePoint, error) {
+			return all, nil
+		}, tt.search, Options{Mode: tt.mode}, func(c CodePoint) error {
+			got = append(got, c.String())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q in mode %d: got %q, want %q", tt.search, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestExcludedBlockBetweenBlocks(t *testing.T) {
+	var want []CodePoint
+	for _, c := range readFixture(t) {
+		if c.Category.Name != "Runic" {
+			want = append(want, c)
+		}
+	}
+	c := &Cache{BaseDir: t.TempDir(), NamesList: testNamesList, Exclude: []string{"runic"}}
+	got, err := c.loadNamesList(context.Background(), false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for _, c := range got {
+		if c.Chr == 0x2190 && (c.Category.Name != "Arrows" || c.Category.Description != "" || c.Subcategory != "") {
+			t.Errorf("got block %+v and subcategory %q for U+2190, want Arrows without a subcategory", c.Category, c.Subcategory)
+		}
+	}
+}
+
+func TestCaseFolding(t *testing.T) {
+	folds := []struct{ s, want string }{
+		{"STRASSE", "strasse"},
+		{"Straße", "strasse"},
+		{"ẞ", "ss"},
+		{"İ", "i̇"},
+		{"ı", "ı"},
+		{"I", "i"},
+		{"ǅ", "ǆ"},
+		{"Ǆ", "ǆ"},
+		{"ǆ", "ǆ"},
+	}
+	for _, tt := range folds {
+		if got := foldCase(tt.s); got != tt.want {
+			t.Errorf("foldCase(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+
+	// The search term is case folded before it is compared, like the
+	// matcher does.
+	tests := []struct {
+		s, term           string
+		contains, isEqual bool
+	}{
+		{"STRASSE", "straße", true, true},
+		{"Straße", "STRASSE", true, true},
+		{"Große Straße", "SS", true, false},
+		// İ folds to i with a combining dot above, which is in the way of
+		// the i without one.
+		{"İstanbul", "istanbul", false, false},
+		{"İstanbul", "stanbul", true, false},
+		{"Istanbul", "ıstanbul", false, false},
+		{"ı", "I", false, false},
+		{"ǅ", "Ǆ", true, true},
+		{"ǆ", "ǅ", true, true},
+		{"LATIN SMALL LETTER DZ", "dz", true, false},
+	}
+	for _, tt := range tests {
+		term := foldCase(tt.term)
+		if got := containsFold(tt.s, term); got != tt.contains {
+			t.Errorf("containsFold(%q, %q) = %t, want %t", tt.s, term, got, tt.contains)
+		}
+		if got := equalFold(tt.s, term); got != tt.isEqual {
+			t.Errorf("equalFold(%q, %q) = %t, want %t", tt.s, term, got, tt.isEqual)
+		}
+	}
+}
+
+func TestParseNamesListSkipsSurrogates(t *testing.T) {
+	const namesList = "@@\tD800\tHigh Surrogates\tDB7F\n" +
+		"D800\t<Non Private Use High Surrogate, First>\n" +
+		"\t* the first high surrogate\n" +
+		"DB7F\t<Non Private Use High Surrogate, Last>\n" +
+		"@@\tE000\tPrivate Use Area\tF8FF\n" +
+		"E000\t<Private Use, First>\n"
+	cp, err := SearchReader(strings.NewReader(namesList), "", Options{})
+	if err != nil {
+		t.Fatalf("got error %v, want the bounds of the surrogates skipped silently", err)
+	}
+	if len(cp) != 1 || cp[0].Chr != 0xE000 || len(cp[0].FullDesc) != 1 {
+		t.Errorf("got %+v, want only U+E000 without the comment of U+D800", cp)
+	}
+
+	path := filepath.Join(t.TempDir(), "NamesList.txt")
+	if err := os.WriteFile(path, []byte(namesList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Cache{BaseDir: t.TempDir(), NamesList: path}
+	blocks, err := c.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range blocks {
+		names = append(names, b.Name)
+	}
+	if want := []string{"High Surrogates", "Private Use Area"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got blocks %q, want %q", names, want)
+	}
+}
+
+func TestPropertiesReadOnlyTheirFile(t *testing.T) {
+	tests := []struct {
+		props Property
+		want  string
+	}{
+		{PropUnicodeData, unicodeDataFile},
+		{PropScript, scriptsFile},
+		{PropAge, derivedAgeFile},
+	}
+	for _, tt := range tests {
+		// Nothing is cached, so the only file missing is the one of the
+		// property.
+		c := &Cache{BaseDir: t.TempDir(), Offline: true, NamesList: testNamesList, Properties: tt.props}
+		err := c.SearchContext(context.Background(), "latin", Options{}, func(CodePoint) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), tt.want+" is not cached") {
+			t.Errorf("properties %b: got error %v, want one about %s", tt.props, err, tt.want)
+		}
+	}
+	c := &Cache{BaseDir: t.TempDir(), Offline: true, NamesList: testNamesList}
+	if err := c.SearchContext(context.Background(), "latin", Options{}, func(CodePoint) error { return nil }); err != nil {
+		t.Errorf("no properties: got error %v, want none", err)
+	}
+}
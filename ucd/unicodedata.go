@@ -0,0 +1,111 @@
+package ucd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// properties are the fields of UnicodeData.txt for a character that the
+// NamesList does not have.
+type properties struct {
+	generalCategory string
+	bidiClass       string
+	numericValue    string
+}
+
+// propertyRange is a range of characters UnicodeData.txt lists as a first
+// and last entry, like the CJK ideographs, which share their properties.
+type propertyRange struct {
+	first, last rune
+	properties
+}
+
+// unicodeData is the parsed UnicodeData.txt.
+type unicodeData struct {
+	chars  map[rune]properties
+	ranges []propertyRange
+}
+
+func (d *unicodeData) lookup(r rune) (properties, bool) {
+	if p, ok := d.chars[r]; ok {
+		return p, true
+	}
+	for _, pr := range d.ranges {
+		if r >= pr.first && r <= pr.last {
+			return pr.properties, true
+		}
+	}
+	return properties{}, false
+}
+
+// loadUnicodeData returns the parsed UnicodeData.txt.
+func (c *Cache) loadUnicodeData(ctx context.Context) (d *unicodeData, err error) {
+	err = c.parseFile(ctx, c.UnicodeData, unicodeDataFile, func(r io.Reader) (perrs ParseErrors, err error) {
+		d, perrs, err = parseUnicodeData(r)
+		return perrs, err
+	})
+	return d, err
+}
+
+// addProperties sets the properties of UnicodeData.txt on the entries of cp.
+func (d *unicodeData) addProperties(cp []CodePoint) {
+	for i := range cp {
+		p, ok := d.lookup(cp[i].Chr)
+		if !ok {
+			continue
+		}
+		cp[i].GeneralCategory = p.generalCategory
+		cp[i].BidiClass = p.bidiClass
+		cp[i].NumericValue = p.numericValue
+	}
+}
+
+// parseUnicodeData parses the semicolon separated fields of each line of
+// UnicodeData.txt read from r.
+func parseUnicodeData(r io.Reader) (*unicodeData, ParseErrors, error) {
+	d := &unicodeData{chars: make(map[rune]properties)}
+	var perrs ParseErrors
+	var first rune = -1
+	var lineNr int
+	rdr := bufio.NewScanner(r)
+	rdr.Buffer(nil, maxLineSize)
+	for rdr.Scan() {
+		lineNr++
+		line := rdr.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) < 15 {
+			perrs.add(unicodeDataFile, lineNr, fmt.Errorf("invalid format, expected 15 fields, got %d", len(fields)))
+			continue
+		}
+		i, err := strconv.ParseInt(fields[0], 16, 32)
+		if err != nil {
+			perrs.add(unicodeDataFile, lineNr, fmt.Errorf("invalid rune %q: %w", fields[0], err))
+			continue
+		}
+		p := properties{
+			generalCategory: fields[2],
+			bidiClass:       fields[4],
+			numericValue:    fields[8],
+		}
+		switch name := fields[1]; {
+		case strings.HasSuffix(name, ", First>"):
+			first = rune(i)
+		case strings.HasSuffix(name, ", Last>") && first >= 0:
+			d.ranges = append(d.ranges, propertyRange{first, rune(i), p})
+			first = -1
+		default:
+			d.chars[rune(i)] = p
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %w", unicodeDataFile, err)
+	}
+	return d, perrs, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// displayWidth returns the number of terminal columns r takes up: none for
+// combining marks and other characters that do not advance the cursor, two
+// for East Asian wide and fullwidth characters, which include most emoji,
+// and one for the rest.
+func displayWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}